@@ -5,7 +5,6 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"math/big"
-	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -20,9 +19,21 @@ var (
 )
 
 type AccountConfig struct {
-	GasLimit   uint64
-	PrivateFor string
-	MangeNonce bool
+	GasLimit uint64
+	// GasPrice is the gas price, in wei, for every transaction. It defaults
+	// to 0 which is what quorum expects. Set SuggestGasPrice to have the node
+	// recommend one instead (needed for upstream geth and besu). All
+	// transactions are legacy transactions, the quorum go-ethereum this is
+	// built against has no EIP-1559 dynamic fee transactions.
+	GasPrice        uint64 `mapstructure:"gas-price"`
+	SuggestGasPrice bool   `mapstructure:"suggest-gas-price"`
+	PrivateFor      string
+	MangeNonce      bool
+
+	// ChainID selects the EIP-155 chain id public transactions are signed
+	// for. 0 asks the node and -1 signs without replay protection. See
+	// ResolveChainID.
+	ChainID int64 `mapstructure:"chain-id"`
 }
 
 // AcountSet groups a set of accounts together. Each thread works with its own
@@ -55,7 +66,7 @@ func (a AccountSet) WithTimeout(parent context.Context, d time.Duration, i int)
 	}
 }
 
-func NewAccountSet(ctx context.Context, ethC *ethclient.Client, cfg *AccountConfig, n int) (AccountSet, error) {
+func NewAccountSet(ctx context.Context, ethC *ethclient.Client, cfg *AccountConfig, chainID *big.Int, n int) (AccountSet, error) {
 
 	a := AccountSet{}
 	a.Wallets = make([]common.Address, n)
@@ -77,12 +88,12 @@ func NewAccountSet(ctx context.Context, ethC *ethclient.Client, cfg *AccountConf
 		pubHash := crypto.Keccak256(pubBytes[1:]) // skip the compression indicator
 		copy(a.Wallets[i][:], pubHash[12:])       // wallet address is the trailing 20 bytes
 
-		a.Auth[i] = bind.NewKeyedTransactor(a.Keys[i])
-		if cfg.PrivateFor != "" {
-			a.Auth[i].PrivateFor = strings.Split(cfg.PrivateFor, ":")
+		a.Auth[i] = NewKeyedTransactor(a.Keys[i], chainID)
+		if sets := ParsePrivateFor(cfg.PrivateFor); len(sets) != 0 {
+			a.Auth[i].PrivateFor = sets[0]
 		}
 		a.Auth[i].GasLimit = cfg.GasLimit
-		a.Auth[i].GasPrice = big.NewInt(0)
+		a.Auth[i].GasPrice = new(big.Int).SetUint64(cfg.GasPrice)
 
 		if !cfg.MangeNonce {
 			continue
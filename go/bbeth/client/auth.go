@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AuthConfig configures the credentials presented to an endpoint. At most one
+// of Token or JWTSecret should be set. If neither is set, and the endpoint url
+// has userinfo, basic auth is used.
+type AuthConfig struct {
+	// Token is sent as a static bearer token
+	Token string
+	// JWTSecret is the name of a file holding a hex encoded 32 byte secret, as
+	// used by geth's --authrpc.jwtsecret. A fresh HS256 token is signed for
+	// every request.
+	JWTSecret string
+}
+
+func (cfg AuthConfig) IsEmpty() bool {
+	return cfg.Token == "" && cfg.JWTSecret == ""
+}
+
+// authTransport sets the Authorization header for every request
+type authTransport struct {
+	token     string
+	jwtSecret []byte
+	next      http.RoundTripper
+}
+
+func newAuthTransport(cfg AuthConfig, next http.RoundTripper) (*authTransport, error) {
+
+	if cfg.Token != "" && cfg.JWTSecret != "" {
+		return nil, fmt.Errorf("an auth token and a jwt secret can not both be set")
+	}
+
+	t := &authTransport{token: cfg.Token, next: next}
+	if cfg.JWTSecret != "" {
+		var err error
+		if t.jwtSecret, err = ReadJWTSecret(cfg.JWTSecret); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// authorization returns the Authorization header value for the configured
+// token or jwt secret. It returns "" if neither is configured.
+func (t *authTransport) authorization(ctx context.Context) (string, error) {
+	switch {
+	case t.jwtSecret != nil:
+		token, err := NewJWTToken(t.jwtSecret, time.Now())
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	case t.token != "":
+		return "Bearer " + t.token, nil
+	}
+	return "", nil
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	// RoundTrippers must not modify the callers request
+	req = req.Clone(req.Context())
+
+	authorization, err := t.authorization(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case authorization != "":
+		req.Header.Set("Authorization", authorization)
+	case req.URL.User != nil:
+		password, _ := req.URL.User.Password()
+		req.SetBasicAuth(req.URL.User.Username(), password)
+	}
+	// Don't leak the credentials in the url to the server (or its logs)
+	req.URL.User = nil
+
+	return t.next.RoundTrip(req)
+}
+
+// ReadJWTSecret reads a hex encoded 32 byte secret from fileName
+func ReadJWTSecret(fileName string) ([]byte, error) {
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("reading jwt secret: %w", err)
+	}
+	secret := common.FromHex(strings.TrimSpace(string(b)))
+	if len(secret) != 32 {
+		return nil, fmt.Errorf("jwt secret in `%s' must be 32 hex encoded bytes", fileName)
+	}
+	return secret, nil
+}
+
+// NewJWTToken returns an HS256 token with the iat claim set to now. This is
+// the form geth requires on its authenticated rpc endpoints.
+func NewJWTToken(secret []byte, now time.Time) (string, error) {
+
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{"iat": now.Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+
+	return signingInput + "." + enc.EncodeToString(mac.Sum(nil)), nil
+}
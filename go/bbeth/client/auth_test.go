@@ -0,0 +1,70 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewJWTToken(t *testing.T) {
+
+	secret := make([]byte, 32)
+	for i := range secret {
+		secret[i] = byte(i)
+	}
+	now := time.Unix(1634000000, 0)
+
+	token, err := NewJWTToken(secret, now)
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	enc := base64.RawURLEncoding
+
+	var claims map[string]int64
+	b, err := enc.DecodeString(parts[1])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &claims))
+	assert.Equal(t, now.Unix(), claims["iat"])
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	assert.Equal(t, enc.EncodeToString(mac.Sum(nil)), parts[2])
+}
+
+func TestAuthTransport(t *testing.T) {
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	cfg := TransportConfig{}
+	cfg.SetDefaults()
+
+	hc, err := cfg.NewHTTPClient(time.Second, AuthConfig{Token: "sekret"})
+	require.NoError(t, err)
+	_, err = hc.Get(srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer sekret", got)
+
+	hc, err = cfg.NewHTTPClient(time.Second, AuthConfig{})
+	require.NoError(t, err)
+	_, err = hc.Get(strings.Replace(srv.URL, "http://", "http://alice:pw@", 1))
+	require.NoError(t, err)
+	assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("alice:pw")), got)
+
+	_, err = cfg.NewHTTPClient(time.Second, AuthConfig{Token: "a", JWTSecret: "b"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// DeployedContract is the record written by SaveContractAddress so that
+// subsequent runs can re-use a deployment.
+type DeployedContract struct {
+	Address     common.Address `json:"address"`
+	BlockNumber uint64         `json:"blocknumber"`
+}
+
+// SaveContractAddress writes the deployed contract record to fileName as json
+func SaveContractAddress(fileName string, address common.Address, blockNumber uint64) error {
+	b, err := json.MarshalIndent(DeployedContract{Address: address, BlockNumber: blockNumber}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, append(b, '\n'), 0644)
+}
+
+// ParseContractAddress accepts either a hex address or the name of a file
+// written by SaveContractAddress. Relative file names are relative to cfgDir.
+func ParseContractAddress(cfgDir, value string) (common.Address, error) {
+
+	if common.IsHexAddress(value) {
+		return common.HexToAddress(value), nil
+	}
+
+	fileName := value
+	if !filepath.IsAbs(fileName) {
+		fileName = filepath.Join(cfgDir, fileName)
+	}
+	var deployed DeployedContract
+	if err := common.LoadJSON(fileName, &deployed); err != nil {
+		return common.Address{}, fmt.Errorf(
+			"contract address `%s' is not a hex address or a readable file: %v", value, err)
+	}
+	return deployed.Address, nil
+}
+
+// CheckContractCode returns an error if there is no code at the address
+// (eth_getCode)
+func CheckContractCode(ctx context.Context, ethC *ethclient.Client, address common.Address) error {
+	code, err := ethC.CodeAt(ctx, address, nil)
+	if err != nil {
+		return fmt.Errorf("eth_getCode %s: %w", address.Hex(), err)
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("no contract code at %s", address.Hex())
+	}
+	return nil
+}
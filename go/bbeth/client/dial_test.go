@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echoAPI struct{}
+
+func (echoAPI) Echo(s string) string { return s }
+
+func TestIsIPCEndpoint(t *testing.T) {
+
+	for _, tc := range []struct {
+		endpoint string
+		ipc      bool
+	}{
+		{"/data/geth.ipc", true},
+		{"geth.ipc", true},
+		{"./data/geth.ipc", true},
+		// Mistyped urls are not socket paths
+		{"localhost:8545", false},
+		{"node0", false},
+		{"data/geth", false},
+		{"ipc:///data/geth.ipc", true},
+		{"http://node0:8545", false},
+		{"https://node0", false},
+		{"ws://node0:8546", false},
+		{"wss://node0", false},
+		{"http://%zz", false},
+	} {
+		assert.Equal(t, tc.ipc, IsIPCEndpoint(tc.endpoint), tc.endpoint)
+	}
+}
+
+func TestDialRPC(t *testing.T) {
+
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("test", echoAPI{}))
+	defer srv.Stop()
+
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+	wsSrv := httptest.NewServer(srv.WebsocketHandler([]string{"*"}))
+	defer wsSrv.Close()
+
+	// Unix socket paths are limited to ~100 bytes, t.TempDir can be longer
+	dir, err := os.MkdirTemp("", "bbeth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ipcPath := filepath.Join(dir, "geth.ipc")
+	l, err := net.Listen("unix", ipcPath)
+	require.NoError(t, err)
+	go srv.ServeListener(l)
+	defer l.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(wsSrv.URL, "http")
+
+	for _, tc := range []struct {
+		name     string
+		endpoint string
+		headers  []string
+		err      bool
+	}{
+		{name: "http", endpoint: httpSrv.URL},
+		{name: "http headers", endpoint: httpSrv.URL, headers: []string{"X-Api-Key: abc"}},
+		{name: "ws", endpoint: wsURL},
+		{name: "ws headers", endpoint: wsURL, headers: []string{"X-Api-Key: abc"}, err: true},
+		{name: "ipc path", endpoint: ipcPath},
+		{name: "ipc url", endpoint: "ipc://" + ipcPath},
+		{name: "unknown scheme", endpoint: "ftp://node0", err: true},
+		{name: "no scheme", endpoint: "localhost:8545", err: true},
+		{name: "no host", endpoint: "http://", err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+
+			o := &clientOptions{transport: TransportConfig{Headers: tc.headers}}
+			c, err := dialRPC(tc.endpoint, time.Second, o)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer c.Close()
+
+			var got string
+			require.NoError(t, c.CallContext(context.Background(), &got, "test_echo", tc.name))
+			assert.Equal(t, tc.name, got)
+		})
+	}
+}
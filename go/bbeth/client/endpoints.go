@@ -0,0 +1,106 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Endpoint describes how to reach a single node. Endpoints are either listed
+// explicitly in an endpoints file or derived from the --eth or --staticnodes
+// options.
+type Endpoint struct {
+	Name    string `yaml:"name"`
+	Eth     string `yaml:"eth"`
+	Tessera string `yaml:"tessera"`
+	// PrivateFrom is the tessera public key private transactions are sent
+	// from. Nodes with a key can be checked for private state they should not
+	// have.
+	PrivateFrom string `yaml:"private-from"`
+	// Weight is the relative share of the threads this endpoint should get.
+	// Zero is treated as 1.
+	Weight int `yaml:"weight"`
+	// Validator marks nodes which take part in consensus, so that load can be
+	// kept off them.
+	Validator bool `yaml:"validator"`
+
+	// Optional credentials, overriding the global options for this endpoint
+	AuthToken        string `yaml:"auth-token"`
+	JWTSecret        string `yaml:"jwt-secret"`
+	TesseraAuthToken string `yaml:"tessera-auth-token"`
+	TesseraJWTSecret string `yaml:"tessera-jwt-secret"`
+
+	// EthHost and TesseraHost are the host names the Eth and Tessera urls
+	// were resolved from, if their hosts were replaced by an address. They
+	// are not read from the endpoints file.
+	EthHost     string `yaml:"-"`
+	TesseraHost string `yaml:"-"`
+}
+
+// EndpointName names a node known only by its url, for reports: the host and
+// port, or the socket path for ipc. Credentials in the url are left out.
+func EndpointName(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	if IsIPCEndpoint(endpoint) {
+		return u.Host + u.Path
+	}
+	return u.Host
+}
+
+// Auth returns the endpoint specific eth credentials, or def if there are none
+func (ep Endpoint) Auth(def AuthConfig) AuthConfig {
+	auth := AuthConfig{Token: ep.AuthToken, JWTSecret: ep.JWTSecret}
+	if auth.IsEmpty() {
+		return def
+	}
+	return auth
+}
+
+// TesseraAuth returns the endpoint specific tessera credentials, or def if
+// there are none
+func (ep Endpoint) TesseraAuth(def AuthConfig) AuthConfig {
+	auth := AuthConfig{Token: ep.TesseraAuthToken, JWTSecret: ep.TesseraJWTSecret}
+	if auth.IsEmpty() {
+		return def
+	}
+	return auth
+}
+
+// LoadEndpoints reads a yaml (or json) list of endpoints from fileName.
+// Entries without a name are named for their position in the list.
+func LoadEndpoints(fileName string) ([]Endpoint, error) {
+
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("loading endpoints `%s': %w", fileName, err)
+	}
+
+	var endpoints []Endpoint
+	if err = yaml.UnmarshalStrict(b, &endpoints); err != nil {
+		return nil, fmt.Errorf("parsing endpoints `%s': %w", fileName, err)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints in `%s'", fileName)
+	}
+
+	for i := range endpoints {
+		if endpoints[i].Eth == "" {
+			return nil, fmt.Errorf("endpoint %d in `%s' has no eth url", i, fileName)
+		}
+		if err := CheckEndpoint(endpoints[i].Eth); err != nil {
+			return nil, fmt.Errorf("endpoint %d in `%s': %w", i, fileName, err)
+		}
+		if endpoints[i].Name == "" {
+			endpoints[i].Name = fmt.Sprintf("node-%d", i)
+		}
+		if endpoints[i].Weight < 0 {
+			return nil, fmt.Errorf("endpoint %s in `%s' has a negative weight", endpoints[i].Name, fileName)
+		}
+	}
+	return endpoints, nil
+}
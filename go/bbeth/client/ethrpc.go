@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
-	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -36,26 +39,61 @@ func GetBlockByNumber(ctx context.Context, eth *ethclient.Client, retries int, b
 	return block, err
 }
 
+// CheckReceipt waits for the receipt of tx and returns the outcome
 func CheckReceipt(
-	ethC *ethclient.Client, tx *types.Transaction, retries int, expectedLatency time.Duration) bool {
+	ethC *ethclient.Client, tx *types.Transaction, retries int, expectedLatency time.Duration) TxResult {
 
-	// start := time.Now()
-	// fmt.Printf("checkreceipt: %s\n", tx.Hash().Hex())
+	return ReceiptResult(WaitReceipt(ethC, tx, retries, expectedLatency))
+}
+
+// WaitReceipt polls for the receipt of tx, applying a backoff between each
+// try. It returns the last error seen if no receipt is found after retries
+// attempts.
+func WaitReceipt(
+	ethC *ethclient.Client, tx *types.Transaction, retries int, expectedLatency time.Duration) (*types.Receipt, error) {
+
+	err := fmt.Errorf("%w: %s", ErrNoReceipt, tx.Hash().Hex())
 	for i := 0; i < retries; i++ {
 		ctx, cancel := context.WithTimeout(context.Background(), expectedLatency)
-		r, err := ethC.TransactionReceipt(ctx, tx.Hash())
+		r, rerr := ethC.TransactionReceipt(ctx, tx.Hash())
 		cancel()
-		if r == nil || err != nil {
-			// fmt.Printf("trying for %v, backoff & retry: err=%v\n", time.Since(start), err)
+		if r == nil || rerr != nil {
+			if rerr != nil {
+				err = rerr
+			}
 			time.Sleep(backoffDuration(i))
 			continue
 		}
-		if r.Status == 1 {
-			return true
-		}
-		return false
+		return r, nil
 	}
-	return false
+	return nil, err
+}
+
+// SendRawTransaction submits a hex encoded, signed, transaction using
+// eth_sendRawTransaction.
+func SendRawTransaction(ctx context.Context, ethRPC *rpc.Client, raw string) error {
+	return ethRPC.CallContext(ctx, nil, "eth_sendRawTransaction", raw)
+}
+
+// SendRawTransactions submits the hex encoded, signed, transactions in a
+// single json-rpc batch request. The returned error is for the request as a
+// whole, if it is nil the slice holds the result for each transaction.
+func SendRawTransactions(ctx context.Context, ethRPC *rpc.Client, raws []string) ([]error, error) {
+
+	batch := make([]rpc.BatchElem, len(raws))
+	for i, raw := range raws {
+		// The batch client fails to unmarshal into a nil Result, even though
+		// the hash is not needed
+		batch[i] = rpc.BatchElem{Method: "eth_sendRawTransaction", Args: []interface{}{raw}, Result: new(common.Hash)}
+	}
+	if err := ethRPC.BatchCallContext(ctx, batch); err != nil {
+		return nil, err
+	}
+	errs := make([]error, len(raws))
+	for i := range batch {
+		errs[i] = batch[i].Error
+	}
+	return errs, nil
 }
 
 // derived from https://blog.gopheracademy.com/advent-2014/backoff/
@@ -75,23 +113,20 @@ func jitter(ms int) int {
 	return ms/2 + rand.Intn(ms)
 }
 
-func NewEthClient(ethEndpoint string) (*ethclient.Client, error) {
-
-	ethRPC, err := rpc.DialHTTPWithClient(ethEndpoint, &http.Client{Timeout: time.Second * 10})
+func NewEthClient(ethEndpoint string, clientTimeout time.Duration, opts ...ClientOption) (*ethclient.Client, error) {
+	ethC, err := NewClient(ethEndpoint, "", clientTimeout, opts...)
 	if err != nil {
 		return nil, err
 	}
-	ethClient := ethclient.NewClient(ethRPC)
-	if ethClient == nil {
-		return nil, fmt.Errorf("failed creating ethclient")
-	}
-
-	return ethClient, nil
+	return ethC.Client, nil
 }
 
-func NewTransactor(ethEndpoint, tesseraEndpoint string, clientTimeout time.Duration) (*ethclient.Client, error) {
-	ethC, err := NewClient(ethEndpoint, tesseraEndpoint, clientTimeout)
-	return ethC.Client, err
+func NewTransactor(ethEndpoint, tesseraEndpoint string, clientTimeout time.Duration, opts ...ClientOption) (*ethclient.Client, error) {
+	ethC, err := NewClient(ethEndpoint, tesseraEndpoint, clientTimeout, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return ethC.Client, nil
 }
 
 // Client makes both the ethclient and the underlying rpc.Client available on the same struct
@@ -100,13 +135,67 @@ type Client struct {
 	RPC *rpc.Client
 }
 
+type clientOptions struct {
+	transport   TransportConfig
+	auth        AuthConfig
+	tesseraAuth AuthConfig
+	host        string
+	tesseraHost string
+}
+
+type ClientOption func(*clientOptions)
+
+// WithTransport configures the http transport for the eth and tessera
+// connections. Without it the go defaults are used.
+func WithTransport(cfg TransportConfig) ClientOption {
+	return func(o *clientOptions) {
+		o.transport = cfg
+	}
+}
+
+// WithAuth sets the credentials for the eth endpoint
+func WithAuth(auth AuthConfig) ClientOption {
+	return func(o *clientOptions) {
+		o.auth = auth
+	}
+}
+
+// WithHost sets the host name the eth endpoint address was resolved from. See
+// TransportConfig.NewHostHTTPClient.
+func WithHost(host string) ClientOption {
+	return func(o *clientOptions) {
+		o.host = host
+	}
+}
+
+// WithTesseraHost sets the host name the tessera endpoint address was
+// resolved from
+func WithTesseraHost(host string) ClientOption {
+	return func(o *clientOptions) {
+		o.tesseraHost = host
+	}
+}
+
+// WithTesseraAuth sets the credentials for the tessera endpoint
+func WithTesseraAuth(auth AuthConfig) ClientOption {
+	return func(o *clientOptions) {
+		o.tesseraAuth = auth
+	}
+}
+
 func NewClient(
-	ethEndpoint, tesseraEndpoint string, clientTimeout time.Duration,
+	ethEndpoint, tesseraEndpoint string, clientTimeout time.Duration, opts ...ClientOption,
 ) (*Client, error) {
 
+	o := clientOptions{}
+	o.transport.SetDefaults()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	c := &Client{}
 
-	ethRPC, err := rpc.DialHTTPWithClient(ethEndpoint, &http.Client{Timeout: clientTimeout})
+	ethRPC, err := dialRPC(ethEndpoint, clientTimeout, &o)
 	if err != nil {
 		return nil, err
 	}
@@ -118,11 +207,104 @@ func NewClient(
 	c.Client = ethClient
 
 	if tesseraEndpoint != "" {
-		ethClient, err = ethClient.WithPrivateTransactionManager(tesseraEndpoint)
-		c.Client = ethClient
+		tesseraHTTPClient, err := o.transport.NewHostHTTPClient(clientTimeout, o.tesseraHost, o.tesseraAuth)
 		if err != nil {
 			return nil, err
 		}
+		c.Client = ethclient.NewClientWithPTM(ethRPC, newTesseraClient(tesseraEndpoint, tesseraHTTPClient))
 	}
 	return c, nil
 }
+
+// IsIPCEndpoint returns true if the endpoint is an ipc socket path rather
+// than a url. ipc:// urls are accepted, and plain paths if they are absolute
+// or end in .ipc. Anything else without a scheme, eg localhost:8545, is more
+// likely a mistyped url than a socket.
+func IsIPCEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "ipc":
+		return true
+	case "":
+		return filepath.IsAbs(endpoint) || strings.HasSuffix(endpoint, ".ipc")
+	}
+	return false
+}
+
+// CheckEndpoint returns an error if the endpoint is neither an ipc socket path
+// nor an http(s) or ws(s) url with a host
+func CheckEndpoint(endpoint string) error {
+
+	if IsIPCEndpoint(endpoint) {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err == nil && u.Host != "" {
+		switch u.Scheme {
+		case "http", "https", "ws", "wss":
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"unsupported endpoint `%s', use an http(s):// or ws(s):// url, or an absolute or .ipc socket path",
+		endpoint)
+}
+
+// dialRPC connects to the endpoint using the transport implied by its scheme:
+// http(s), ws(s) or ipc.
+func dialRPC(endpoint string, clientTimeout time.Duration, o *clientOptions) (*rpc.Client, error) {
+
+	if err := CheckEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clientTimeout)
+	defer cancel()
+
+	switch u.Scheme {
+	case "http", "https":
+		httpClient, err := o.transport.NewHostHTTPClient(clientTimeout, o.host, o.auth)
+		if err != nil {
+			return nil, err
+		}
+		return rpc.DialHTTPWithClient(endpoint, httpClient)
+
+	case "ws", "wss":
+		// The websocket dial only supports credentials, which are presented on
+		// the upgrade request. Basic auth from the url userinfo is handled by
+		// the dialer. A resolved host name is verified against the server
+		// certificate, but it can't be sent as the Host header.
+		if len(o.transport.Headers) != 0 {
+			return nil, fmt.Errorf("custom headers are not supported for websocket endpoints")
+		}
+		tlsConfig, err := o.transport.tlsConfig(o.host)
+		if err != nil {
+			return nil, err
+		}
+		if !o.auth.IsEmpty() {
+			t, err := newAuthTransport(o.auth, nil)
+			if err != nil {
+				return nil, err
+			}
+			ctx = rpc.WithCredentialsProvider(ctx, t.authorization)
+		}
+		return rpc.DialWebsocketWithCustomTLS(ctx, endpoint, "", tlsConfig)
+
+	case "", "ipc":
+		path := endpoint
+		if u.Scheme == "ipc" {
+			path = u.Host + u.Path
+		}
+		return rpc.DialIPC(ctx, path)
+
+	default:
+		return nil, fmt.Errorf("no known transport for endpoint `%s'", endpoint)
+	}
+}
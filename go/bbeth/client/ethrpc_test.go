@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEth accepts raw transactions unless their first byte is 0xff
+type fakeEth struct {
+	mu   sync.Mutex
+	sent []hexutil.Bytes
+}
+
+func (f *fakeEth) SendRawTransaction(raw hexutil.Bytes) (common.Hash, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(raw) != 0 && raw[0] == 0xff {
+		return common.Hash{}, errors.New("nonce too low")
+	}
+	f.sent = append(f.sent, raw)
+	return crypto.Keccak256Hash(raw), nil
+}
+
+func TestSendRawTransactions(t *testing.T) {
+
+	eth := &fakeEth{}
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", eth))
+	defer srv.Stop()
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+
+	errs, err := SendRawTransactions(context.Background(), c, []string{"0x01", "0xff01", "0x02"})
+	require.NoError(t, err)
+	require.Len(t, errs, 3)
+	assert.NoError(t, errs[0])
+	assert.EqualError(t, errs[1], "nonce too low")
+	assert.NoError(t, errs[2])
+	assert.Equal(t, []hexutil.Bytes{{0x01}, {0x02}}, eth.sent)
+
+	// A failure of the request as a whole is not attributed to each
+	// transaction
+	c.Close()
+	errs, err = SendRawTransactions(context.Background(), c, []string{"0x03"})
+	assert.Error(t, err)
+	assert.Nil(t, errs)
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// histogram counts observations into fixed buckets and writes them in the
+// prometheus histogram exposition format. The go-ethereum metrics histograms
+// and timers are sampled, and their prometheus handler exports them as
+// summaries, whose quantiles can't be aggregated across scrapes or runs.
+type histogram struct {
+	name string
+
+	mu sync.Mutex
+	// bounds are the ascending upper bounds of the buckets. counts has an
+	// extra, +Inf, bucket and is not cumulative.
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(name string, bounds []float64) *histogram {
+	return &histogram{name: name, bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// The first bucket whose upper bound is >= v
+	h.counts[sort.SearchFloat64s(h.bounds, v)]++
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n\n", h.name, h.count)
+}
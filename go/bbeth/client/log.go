@@ -0,0 +1,39 @@
+package client
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Log formats accepted by NewLogHandler
+const (
+	LogFormatTerminal = "terminal"
+	LogFormatLogfmt   = "logfmt"
+	LogFormatJSON     = "json"
+)
+
+// NewLogHandler returns a handler which writes records at or above level
+// (trace, debug, info, warn, error or crit) to w in format. logfmt and json
+// are intended for ingestion by log collectors, terminal for people.
+func NewLogHandler(w io.Writer, level, format string) (log.Handler, error) {
+
+	lvl, err := log.LvlFromString(level)
+	if err != nil {
+		return nil, fmt.Errorf("log level `%s': %w", level, err)
+	}
+
+	var f log.Format
+	switch format {
+	case LogFormatTerminal, "":
+		f = log.TerminalFormat(false)
+	case LogFormatLogfmt:
+		f = log.LogfmtFormat()
+	case LogFormatJSON:
+		f = log.JSONFormat()
+	default:
+		return nil, fmt.Errorf("log format `%s' is not one of terminal, logfmt or json", format)
+	}
+	return log.LvlFilterHandler(lvl, log.StreamHandler(w, f)), nil
+}
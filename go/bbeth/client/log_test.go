@@ -0,0 +1,42 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogHandler(t *testing.T) {
+
+	var buf bytes.Buffer
+	h, err := NewLogHandler(&buf, "info", LogFormatJSON)
+	require.NoError(t, err)
+
+	l := log.New("thread", 1)
+	l.SetHandler(h)
+	l.Debug("filtered")
+	l.Info("sent", "node", "node-0")
+
+	var rec map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	assert.Equal(t, "sent", rec["msg"])
+	assert.Equal(t, "node-0", rec["node"])
+	assert.Equal(t, float64(1), rec["thread"])
+
+	buf.Reset()
+	h, err = NewLogHandler(&buf, "debug", LogFormatLogfmt)
+	require.NoError(t, err)
+	l.SetHandler(h)
+	l.Debug("batch", "n", 3)
+	assert.Contains(t, buf.String(), "msg=batch")
+	assert.Contains(t, buf.String(), "n=3")
+
+	_, err = NewLogHandler(&buf, "loud", LogFormatJSON)
+	assert.Error(t, err)
+	_, err = NewLogHandler(&buf, "info", "xml")
+	assert.Error(t, err)
+}
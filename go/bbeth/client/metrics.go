@@ -0,0 +1,214 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/metrics/prometheus"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Metrics are the live metrics for a run, exposed for prometheus by Serve.
+// All methods are safe to call on a nil *Metrics, so callers need not check
+// whether metrics are enabled.
+type Metrics struct {
+	registry metrics.Registry
+
+	issued metrics.Counter
+	mined  metrics.Counter
+	// inFlight is nil unless TrackInFlight is called
+	inFlight metrics.Gauge
+
+	// Distributions are real histograms, see histogram
+	blockTxs      *histogram
+	blockInterval *histogram
+	inclusion     *histogram
+}
+
+// Histogram bucket upper bounds
+var (
+	blockTxsBuckets = []float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+	secondsBuckets  = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60, 120}
+)
+
+func NewMetrics() *Metrics {
+
+	// The go-ethereum constructors return no-op metrics unless this is set
+	metrics.Enabled = true
+
+	r := metrics.NewRegistry()
+	return &Metrics{
+		registry:      r,
+		issued:        metrics.NewRegisteredCounter("bbeth/issued", r),
+		mined:         metrics.NewRegisteredCounter("bbeth/mined", r),
+		blockTxs:      newHistogram("bbeth_block_txs", blockTxsBuckets),
+		blockInterval: newHistogram("bbeth_block_interval_seconds", secondsBuckets),
+		inclusion:     newHistogram("bbeth_inclusion_seconds", secondsBuckets),
+	}
+}
+
+// TrackInFlight enables the count of transactions issued but not yet mined.
+// It is only meaningful when the transactions are issued by this process, in
+// collect only runs Mined would drive it negative.
+func (m *Metrics) TrackInFlight() {
+	if m == nil || m.inFlight != nil {
+		return
+	}
+	m.inFlight = metrics.NewRegisteredGauge("bbeth/inflight", m.registry)
+}
+
+// ServeHTTP writes the registry metrics, using the go-ethereum prometheus
+// handler, followed by the histograms.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	buf := &bufferedResponse{header: http.Header{}}
+	prometheus.Handler(m.registry).ServeHTTP(buf, r)
+	for _, h := range []*histogram{m.blockTxs, m.blockInterval, m.inclusion} {
+		h.write(&buf.body)
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write(buf.body.Bytes())
+}
+
+// bufferedResponse collects the response of the go-ethereum handler, which
+// sets Content-Length for its own output only
+type bufferedResponse struct {
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(int)             {}
+
+// Serve exposes the metrics on addr at /metrics. The listener is opened
+// before returning so a bad address is reported.
+func (m *Metrics) Serve(addr string) error {
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics listener: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			log.Error("metrics server", "err", err)
+		}
+	}()
+	log.Info("serving metrics", "url", fmt.Sprintf("http://%s/metrics", l.Addr()))
+	return nil
+}
+
+// Issued counts n transactions accepted by a node
+func (m *Metrics) Issued(n int) {
+	if m == nil {
+		return
+	}
+	m.issued.Inc(int64(n))
+	if m.inFlight != nil {
+		m.inFlight.Inc(int64(n))
+	}
+}
+
+// Errored counts a transaction which was not accepted, by the class of err
+func (m *Metrics) Errored(err error) {
+	if m == nil {
+		return
+	}
+	metrics.GetOrRegisterCounter("bbeth/errors/"+ErrorClass(err), m.registry).Inc(1)
+}
+
+// Mined counts n mined transactions
+func (m *Metrics) Mined(n int) {
+	if m == nil {
+		return
+	}
+	m.mined.Inc(int64(n))
+	if m.inFlight != nil {
+		m.inFlight.Dec(int64(n))
+	}
+}
+
+// Block records the number of transactions in a block and the interval since
+// its parent
+func (m *Metrics) Block(ntx int, interval time.Duration) {
+	if m == nil {
+		return
+	}
+	m.blockTxs.observe(float64(ntx))
+	if interval > 0 {
+		m.blockInterval.observe(interval.Seconds())
+	}
+}
+
+// Included records the latency from sending a transaction to seeing it mined
+func (m *Metrics) Included(latency time.Duration) {
+	if m == nil {
+		return
+	}
+	m.inclusion.observe(latency.Seconds())
+}
+
+// Error classes
+const (
+	ErrorClassTimeout    = "timeout"
+	ErrorClassConnection = "connection"
+	ErrorClassNonce      = "nonce"
+	ErrorClassTxPoolFull = "txpool-full"
+	ErrorClassGas        = "gas"
+	ErrorClassRPC        = "rpc"
+	ErrorClassOther      = "other"
+)
+
+// ErrorClass puts an error from sending a transaction into a broad class
+func ErrorClass(err error) string {
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	msg := strings.ToLower(err.Error())
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		switch {
+		case strings.Contains(msg, "nonce") ||
+			strings.Contains(msg, "already known") ||
+			strings.Contains(msg, "known transaction") ||
+			strings.Contains(msg, "replacement transaction"):
+			return ErrorClassNonce
+		case strings.Contains(msg, "txpool is full") ||
+			strings.Contains(msg, "transaction pool is full") ||
+			strings.Contains(msg, "pool_full") ||
+			strings.Contains(msg, "pool is full"):
+			return ErrorClassTxPoolFull
+		case strings.Contains(msg, "underpriced") ||
+			strings.Contains(msg, "insufficient funds") ||
+			strings.Contains(msg, "gas"):
+			return ErrorClassGas
+		}
+		return ErrorClassRPC
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) ||
+		strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "connection reset") ||
+		strings.Contains(msg, "eof") {
+		return ErrorClassConnection
+	}
+	return ErrorClassOther
+}
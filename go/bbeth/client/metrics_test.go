@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRPCError struct{ msg string }
+
+func (e testRPCError) Error() string  { return e.msg }
+func (e testRPCError) ErrorCode() int { return -32000 }
+
+func TestErrorClass(t *testing.T) {
+
+	assert.Equal(t, ErrorClassTimeout, ErrorClass(fmt.Errorf("send: %w", context.DeadlineExceeded)))
+	assert.Equal(t, ErrorClassNonce, ErrorClass(testRPCError{"nonce too low"}))
+	assert.Equal(t, ErrorClassGas, ErrorClass(testRPCError{"transaction underpriced"}))
+	assert.Equal(t, ErrorClassTxPoolFull, ErrorClass(testRPCError{"txpool is full"}))
+	assert.Equal(t, ErrorClassRPC, ErrorClass(testRPCError{"execution reverted"}))
+	assert.Equal(t, ErrorClassConnection, ErrorClass(&net.OpError{Op: "dial", Err: errors.New("refused")}))
+	assert.Equal(t, ErrorClassOther, ErrorClass(errors.New("something else")))
+}
+
+func TestMetricsNil(t *testing.T) {
+	var m *Metrics
+	m.Issued(1)
+	m.Errored(errors.New("x"))
+	m.Mined(1)
+	m.TrackInFlight()
+}
+
+func TestHistogram(t *testing.T) {
+
+	h := newHistogram("test_seconds", []float64{0.1, 1, 10})
+	for _, v := range []float64{0.05, 0.1, 0.5, 2, 20} {
+		h.observe(v)
+	}
+
+	var b strings.Builder
+	h.write(&b)
+	assert.Equal(t, `# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 2
+test_seconds_bucket{le="1"} 3
+test_seconds_bucket{le="10"} 4
+test_seconds_bucket{le="+Inf"} 5
+test_seconds_sum 22.65
+test_seconds_count 5
+
+`, b.String())
+}
+
+func TestMetricsServeHTTP(t *testing.T) {
+
+	scrape := func(m *Metrics) string {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		return w.Body.String()
+	}
+
+	// Collect only, nothing is issued by this process
+	m := NewMetrics()
+	m.Mined(3)
+	m.Block(3, 2*time.Second)
+	m.Included(1500 * time.Millisecond)
+	body := scrape(m)
+	assert.Contains(t, body, "bbeth_mined 3\n")
+	assert.NotContains(t, body, "bbeth_inflight")
+	assert.Contains(t, body, "# TYPE bbeth_block_txs histogram\n")
+	assert.Contains(t, body, "bbeth_block_txs_bucket{le=\"5\"} 1\n")
+	assert.Contains(t, body, "bbeth_block_interval_seconds_bucket{le=\"2\"} 1\n")
+	assert.Contains(t, body, "bbeth_inclusion_seconds_bucket{le=\"1\"} 0\n")
+	assert.Contains(t, body, "bbeth_inclusion_seconds_bucket{le=\"2\"} 1\n")
+	assert.NotContains(t, body, "summary")
+
+	// Loading, the transactions in flight are tracked
+	m = NewMetrics()
+	m.TrackInFlight()
+	m.Issued(5)
+	m.Mined(3)
+	assert.Contains(t, scrape(m), "bbeth_inflight 2\n")
+}
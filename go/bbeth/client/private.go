@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/private/engine"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Privacy flag names, as accepted by ParsePrivacyFlag
+const (
+	PrivacyStandard        = "standard"
+	PrivacyPartyProtection = "party-protection"
+	PrivacyStateValidation = "psv"
+)
+
+// ParsePrivacyFlag returns the quorum privacy flag for name. The empty
+// string is standard private.
+func ParsePrivacyFlag(name string) (engine.PrivacyFlagType, error) {
+	switch name {
+	case "", PrivacyStandard:
+		return engine.PrivacyFlagStandardPrivate, nil
+	case PrivacyPartyProtection:
+		return engine.PrivacyFlagPartyProtection, nil
+	case PrivacyStateValidation:
+		return engine.PrivacyFlagStateValidation, nil
+	}
+	return 0, fmt.Errorf(
+		"unknown privacy flag `%s'. expected one of %s, %s or %s",
+		name, PrivacyStandard, PrivacyPartyProtection, PrivacyStateValidation)
+}
+
+// ParsePrivateFor parses sets of ':' separated tessera keys, separated by
+// ','. eg "A:B,C" is two sets, [A B] and [C]
+func ParsePrivateFor(s string) [][]string {
+	var sets [][]string
+	for _, set := range strings.Split(s, ",") {
+		if set = strings.TrimSpace(set); set == "" {
+			continue
+		}
+		sets = append(sets, strings.Split(set, ":"))
+	}
+	return sets
+}
+
+// PrivateTxArgs are the quorum arguments for eth_sendRawPrivateTransaction.
+// bind.PrivateTxArgs has only PrivateFor, which can't select party protection
+// or private state validation.
+type PrivateTxArgs struct {
+	PrivateFrom string                 `json:"privateFrom,omitempty"`
+	PrivateFor  []string               `json:"privateFor"`
+	PrivacyFlag engine.PrivacyFlagType `json:"privacyFlag"`
+}
+
+// SignPrivate stores the payload of tx with tessera, replaces the payload with
+// its hash and signs the result as a private transaction. The client must
+// have been created with a tessera endpoint.
+func (c *Client) SignPrivate(auth *bind.TransactOpts, tx *types.Transaction, privateFrom string) (*types.Transaction, error) {
+
+	hash, err := c.PreparePrivateTransaction(tx.Data(), privateFrom)
+	if err != nil {
+		return nil, fmt.Errorf("storing private payload: %w", err)
+	}
+
+	var private *types.Transaction
+	if tx.To() == nil {
+		private = types.NewContractCreation(tx.Nonce(), tx.Value(), tx.Gas(), tx.GasPrice(), hash.Bytes())
+	} else {
+		private = types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), tx.GasPrice(), hash.Bytes())
+	}
+	private.SetPrivate()
+
+	return auth.Signer(types.QuorumPrivateTxSigner{}, auth.From, private)
+}
+
+// SendRawPrivateTransaction submits a transaction signed by SignPrivate
+func (c *Client) SendRawPrivateTransaction(ctx context.Context, tx *types.Transaction, args PrivateTxArgs) error {
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return err
+	}
+	return c.RPC.CallContext(ctx, nil, "eth_sendRawPrivateTransaction", hexutil.Encode(raw), args)
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/private/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePrivateFor(t *testing.T) {
+
+	tests := []struct {
+		in   string
+		sets [][]string
+	}{
+		{"", nil},
+		{"A", [][]string{{"A"}}},
+		{"A:B,C", [][]string{{"A", "B"}, {"C"}}},
+		// Blank sets are dropped, the keys are not trimmed
+		{" A:B , ,C,", [][]string{{"A", "B"}, {"C"}}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.sets, ParsePrivateFor(tt.in), tt.in)
+	}
+}
+
+func TestParsePrivacyFlag(t *testing.T) {
+
+	tests := []struct {
+		name string
+		flag engine.PrivacyFlagType
+		err  bool
+	}{
+		{"", engine.PrivacyFlagStandardPrivate, false},
+		{PrivacyStandard, engine.PrivacyFlagStandardPrivate, false},
+		{PrivacyPartyProtection, engine.PrivacyFlagPartyProtection, false},
+		{PrivacyStateValidation, engine.PrivacyFlagStateValidation, false},
+		{"mpp", 0, true},
+	}
+	for _, tt := range tests {
+		flag, err := ParsePrivacyFlag(tt.name)
+		if tt.err {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.flag, flag, tt.name)
+	}
+}
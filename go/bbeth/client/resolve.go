@@ -0,0 +1,64 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// HostResolver resolves node host names to ip addresses once, at startup
+type HostResolver struct {
+	// Resolve, if false, leaves host names as they are
+	Resolve bool
+	// Expand allows a host name with many addresses, eg a headless k8s
+	// service, to resolve to all of them. Otherwise that is an error.
+	Expand bool
+
+	// LookupIP defaults to net.LookupIP
+	LookupIP func(host string) ([]net.IP, error)
+}
+
+// ResolveAll returns the addresses for host. Unless Expand is set there is
+// exactly one. The addresses are sorted so that repeated runs assign threads
+// to nodes the same way regardless of the order dns returns them in.
+func (r HostResolver) ResolveAll(host string) ([]string, error) {
+
+	if !r.Resolve {
+		return []string{host}, nil
+	}
+
+	lookup := r.LookupIP
+	if lookup == nil {
+		lookup = net.LookupIP
+	}
+	ips, err := lookup(host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses for host `%s'", host)
+	}
+	if len(ips) > 1 && !r.Expand {
+		return nil, fmt.Errorf(
+			"cant resolve ambigous host %s. could be any of: %v (see --expand-hosts)", host, ips)
+	}
+
+	sort.Slice(ips, func(i, j int) bool { return bytes.Compare(ips[i].To16(), ips[j].To16()) < 0 })
+
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = ip.String()
+	}
+	return addrs, nil
+}
+
+// ResolveOne returns a single address for host. If Expand is set, and there
+// are many, the first is returned.
+func (r HostResolver) ResolveOne(host string) (string, error) {
+	addrs, err := r.ResolveAll(host)
+	if err != nil {
+		return "", err
+	}
+	return addrs[0], nil
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostResolver(t *testing.T) {
+
+	lookup := func(host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("10.0.0.3"), net.ParseIP("10.0.0.1")}, nil
+	}
+
+	addrs, err := HostResolver{LookupIP: lookup}.ResolveAll("nodes")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nodes"}, addrs)
+
+	_, err = HostResolver{Resolve: true, LookupIP: lookup}.ResolveAll("nodes")
+	assert.Error(t, err)
+
+	r := HostResolver{Resolve: true, Expand: true, LookupIP: lookup}
+	addrs, err = r.ResolveAll("nodes")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.3"}, addrs)
+
+	addr, err := r.ResolveOne("nodes")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", addr)
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrNoReceipt is returned by WaitReceipt if the transaction is not mined
+// before the retries are exhausted
+var ErrNoReceipt = errors.New("no receipt")
+
+// TxResult is the outcome of issuing a transaction
+type TxResult int
+
+const (
+	// TxSent is a transaction accepted by the node whose receipt was not
+	// checked
+	TxSent TxResult = iota
+	TxMined
+	TxReverted
+	// TxNotFound is a transaction accepted by the node but not mined before
+	// the receipt retries ran out
+	TxNotFound
+	TxRPCError
+	TxNonceError
+	TxPoolFull
+	numTxResults
+)
+
+var txResultNames = [numTxResults]string{
+	"sent", "mined", "reverted", "not-found", "rpc-error", "nonce", "txpool-full"}
+
+func (r TxResult) String() string {
+	if r < 0 || r >= numTxResults {
+		return "unknown"
+	}
+	return txResultNames[r]
+}
+
+// Failed is true for all the results other than sent and mined
+func (r TxResult) Failed() bool {
+	return r != TxSent && r != TxMined
+}
+
+// TxResults returns every result, in order
+func TxResults() []TxResult {
+	results := make([]TxResult, numTxResults)
+	for i := range results {
+		results[i] = TxResult(i)
+	}
+	return results
+}
+
+// SendResult returns the result for an error from sending a transaction
+func SendResult(err error) TxResult {
+	switch ErrorClass(err) {
+	case ErrorClassNonce:
+		return TxNonceError
+	case ErrorClassTxPoolFull:
+		return TxPoolFull
+	}
+	return TxRPCError
+}
+
+// ReceiptResult returns the result for the receipt, and error, from
+// WaitReceipt. A receipt request which timed out on the final retry counts
+// as not found.
+func ReceiptResult(r *types.Receipt, err error) TxResult {
+	switch {
+	case errors.Is(err, ErrNoReceipt) || errors.Is(err, ethereum.NotFound) ||
+		errors.Is(err, context.DeadlineExceeded):
+		return TxNotFound
+	case err != nil:
+		return TxRPCError
+	case r.Status != types.ReceiptStatusSuccessful:
+		return TxReverted
+	}
+	return TxMined
+}
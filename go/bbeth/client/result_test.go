@@ -0,0 +1,30 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendResult(t *testing.T) {
+	assert.Equal(t, TxNonceError, SendResult(testRPCError{"nonce too low"}))
+	assert.Equal(t, TxPoolFull, SendResult(testRPCError{"txpool is full"}))
+	assert.Equal(t, TxRPCError, SendResult(testRPCError{"execution reverted"}))
+	assert.Equal(t, TxRPCError, SendResult(errors.New("connection refused")))
+}
+
+func TestReceiptResult(t *testing.T) {
+	assert.Equal(t, TxMined, ReceiptResult(&types.Receipt{Status: types.ReceiptStatusSuccessful}, nil))
+	assert.Equal(t, TxReverted, ReceiptResult(&types.Receipt{Status: types.ReceiptStatusFailed}, nil))
+	assert.Equal(t, TxNotFound, ReceiptResult(nil, fmt.Errorf("%w: 0x01", ErrNoReceipt)))
+	assert.Equal(t, TxNotFound, ReceiptResult(nil, ethereum.NotFound))
+	assert.Equal(t, TxRPCError, ReceiptResult(nil, testRPCError{"boom"}))
+
+	assert.Equal(t, "txpool-full", TxPoolFull.String())
+	assert.False(t, TxMined.Failed())
+	assert.True(t, TxNotFound.Failed())
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RevertReason replays the reverted tx, from receipt r, as an eth_call
+// against the state of the parent of the block it was mined in and returns
+// the reason the call failed. The solidity Error(string) reason is decoded if
+// the node returns the revert data, otherwise the node's error message (eg
+// out of gas) is the reason. The replay does not see the transactions before
+// tx in its block, so if it succeeds the revert depended on them and the
+// reason is empty.
+func RevertReason(ctx context.Context, ethC *ethclient.Client, tx *types.Transaction, r *types.Receipt) (string, error) {
+
+	if tx.IsPrivate() {
+		// The payload is only known to the parties, the public transaction
+		// carries its tessera hash
+		return "", fmt.Errorf("revert reasons are not available for private transactions")
+	}
+
+	from, err := types.Sender(txSigner(tx), tx)
+	if err != nil {
+		return "", err
+	}
+
+	msg := ethereum.CallMsg{
+		From: from, To: tx.To(), Gas: tx.Gas(), GasPrice: tx.GasPrice(), Value: tx.Value(), Data: tx.Data()}
+
+	_, err = ethC.CallContract(ctx, msg, new(big.Int).Sub(r.BlockNumber, big.NewInt(1)))
+	if err == nil {
+		return "", nil
+	}
+	return revertReason(err)
+}
+
+// revertReason returns the reason from an eth_call error
+func revertReason(err error) (string, error) {
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if s, ok := dataErr.ErrorData().(string); ok {
+			data, derr := hexutil.Decode(s)
+			if derr == nil {
+				if reason, uerr := abi.UnpackRevert(data); uerr == nil {
+					return reason, nil
+				}
+			}
+		}
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Error(), nil
+	}
+	// The call itself failed, the reason is unknown
+	return "", err
+}
+
+// txSigner returns the signer which recovers the sender of the public
+// transaction tx
+func txSigner(tx *types.Transaction) types.Signer {
+	if tx.Protected() {
+		return types.NewEIP155Signer(tx.ChainId())
+	}
+	return types.HomesteadSigner{}
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDataError struct {
+	testRPCError
+	data interface{}
+}
+
+func (e testDataError) ErrorData() interface{} { return e.data }
+
+func TestRevertReason(t *testing.T) {
+
+	// abi encoded Error("too low")
+	data := crypto.Keccak256([]byte("Error(string)"))[:4]
+	data = append(data, make([]byte, 31)...)
+	data = append(data, 0x20)
+	data = append(data, make([]byte, 31)...)
+	data = append(data, 7)
+	data = append(data, []byte("too low")...)
+	data = append(data, make([]byte, 32-7)...)
+
+	reason, err := revertReason(testDataError{testRPCError{"execution reverted"}, hexutil.Encode(data)})
+	require.NoError(t, err)
+	assert.Equal(t, "too low", reason)
+
+	// No revert data, the message is all there is
+	reason, err = revertReason(testRPCError{"out of gas"})
+	require.NoError(t, err)
+	assert.Equal(t, "out of gas", reason)
+
+	_, err = revertReason(errors.New("connection refused"))
+	assert.Error(t, err)
+}
+
+// fakeReplay fails every eth_call, recording the block it was made against
+type fakeReplay struct {
+	calledAt []int64
+}
+
+func (f *fakeReplay) Call(args map[string]interface{}, number rpc.BlockNumber) (hexutil.Bytes, error) {
+	f.calledAt = append(f.calledAt, number.Int64())
+	return nil, errors.New("out of gas")
+}
+
+func TestRevertReasonReplay(t *testing.T) {
+
+	f := &fakeReplay{}
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", f))
+	defer srv.Stop()
+	c := rpc.DialInProc(srv)
+	defer c.Close()
+	ethC := ethclient.NewClient(c)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	chainID := big.NewInt(1337)
+	to := common.HexToAddress("0x1000")
+	receipt := &types.Receipt{Status: types.ReceiptStatusFailed, BlockNumber: big.NewInt(5)}
+
+	// Replayed on the state before the block, with both signers
+	for _, signer := range []types.Signer{types.NewEIP155Signer(chainID), types.HomesteadSigner{}} {
+		tx, err := types.SignTx(types.NewTransaction(0, to, new(big.Int), 60000, new(big.Int), nil), signer, key)
+		require.NoError(t, err)
+		reason, err := RevertReason(context.Background(), ethC, tx, receipt)
+		require.NoError(t, err)
+		assert.Equal(t, "out of gas", reason)
+	}
+	assert.Equal(t, []int64{4, 4}, f.calledAt)
+
+	// The payload of a private transaction is only its tessera hash
+	tx := types.NewTransaction(1, to, new(big.Int), 60000, new(big.Int), nil)
+	tx.SetPrivate()
+	_, err = RevertReason(context.Background(), ethC, tx, receipt)
+	assert.Error(t, err)
+	assert.Len(t, f.calledAt, 2)
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// NewKeyedTransactor is bind.NewKeyedTransactor, but public transactions are
+// signed with the EIP-155 signer for chainID. bind always asks for the
+// Homestead signer, which has no replay protection and is rejected by nodes
+// which enforce EIP-155. Private transactions keep the quorum private
+// signer they are given. If chainID is nil the signer asked for is used.
+//
+// Note: the pinned go-ethereum (quorum) has no London signer, so all
+// transactions are legacy transactions.
+func NewKeyedTransactor(key *ecdsa.PrivateKey, chainID *big.Int) *bind.TransactOpts {
+
+	keyAddr := crypto.PubkeyToAddress(key.PublicKey)
+
+	var eip155 types.Signer
+	if chainID != nil {
+		eip155 = types.NewEIP155Signer(chainID)
+	}
+
+	return &bind.TransactOpts{
+		From: keyAddr,
+		Signer: func(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != keyAddr {
+				return nil, errors.New("not authorized to sign this account")
+			}
+			if eip155 != nil && !tx.IsPrivate() {
+				signer = eip155
+			}
+			signature, err := crypto.Sign(signer.Hash(tx).Bytes(), key)
+			if err != nil {
+				return nil, err
+			}
+			return tx.WithSignature(signer, signature)
+		},
+	}
+}
+
+// ResolveChainID returns the chain id to sign with. chainID > 0 is used as
+// is, -1 selects unprotected (Homestead) signing and returns nil, and 0 asks
+// the node (eth_chainId).
+func ResolveChainID(ctx context.Context, ethC *ethclient.Client, chainID int64) (*big.Int, error) {
+	switch {
+	case chainID > 0:
+		return big.NewInt(chainID), nil
+	case chainID < 0:
+		return nil, nil
+	}
+	id, err := ethC.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("eth_chainId (set --chain-id to avoid asking the node): %w", err)
+	}
+	return id, nil
+}
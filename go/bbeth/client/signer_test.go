@@ -0,0 +1,37 @@
+package client
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyedTransactor(t *testing.T) {
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	chainID := big.NewInt(1337)
+
+	auth := NewKeyedTransactor(key, chainID)
+	tx := types.NewTransaction(0, common.Address{1}, new(big.Int), 21000, new(big.Int), nil)
+
+	// bind always asks for the homestead signer
+	signed, err := auth.Signer(types.HomesteadSigner{}, auth.From, tx)
+	require.NoError(t, err)
+	assert.True(t, signed.Protected())
+	assert.Equal(t, chainID, signed.ChainId())
+
+	from, err := types.Sender(types.NewEIP155Signer(chainID), signed)
+	require.NoError(t, err)
+	assert.Equal(t, auth.From, from)
+
+	// Without a chain id the signer asked for is used
+	signed, err = NewKeyedTransactor(key, nil).Signer(types.HomesteadSigner{}, auth.From, tx)
+	require.NoError(t, err)
+	assert.False(t, signed.Protected())
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// tesseraClient stores private payloads with the tessera third party api. It
+// is the same as the ethclient default, other than it uses our configured
+// http client.
+type tesseraClient struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+type storeRawReq struct {
+	Payload string `json:"payload"`
+	From    string `json:"from,omitempty"`
+}
+
+type storeRawResp struct {
+	Key string `json:"key"`
+}
+
+func newTesseraClient(endpoint string, httpClient *http.Client) *tesseraClient {
+	return &tesseraClient{endpoint: strings.TrimSuffix(endpoint, "/"), httpClient: httpClient}
+}
+
+// StoreRaw implements the ethclient private transaction manager interface
+func (tc *tesseraClient) StoreRaw(data []byte, privateFrom string) (common.EncryptedPayloadHash, error) {
+
+	body, err := json.Marshal(&storeRawReq{
+		Payload: base64.StdEncoding.EncodeToString(data),
+		From:    privateFrom,
+	})
+	if err != nil {
+		return common.EncryptedPayloadHash{}, err
+	}
+
+	resp, err := tc.httpClient.Post(tc.endpoint+"/storeraw", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return common.EncryptedPayloadHash{}, fmt.Errorf("unable to invoke /storeraw: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return common.EncryptedPayloadHash{}, fmt.Errorf("tessera /storeraw returned %s", resp.Status)
+	}
+
+	var stored storeRawResp
+	if err := json.NewDecoder(resp.Body).Decode(&stored); err != nil {
+		return common.EncryptedPayloadHash{}, err
+	}
+	return common.Base64ToEncryptedPayloadHash(stored.Key)
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTesseraStoreRaw(t *testing.T) {
+
+	key := bytes.Repeat([]byte{7}, 64)
+
+	var req storeRawReq
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/storeraw" || r.Header.Get("X-Api-Key") != "abc" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(&storeRawResp{Key: base64.StdEncoding.EncodeToString(key)})
+	}))
+	defer srv.Close()
+
+	cfg := TransportConfig{Headers: []string{"X-Api-Key: abc"}}
+	httpClient, err := cfg.NewHTTPClient(0, AuthConfig{})
+	require.NoError(t, err)
+
+	// The trailing / is dropped so the path is not doubled
+	tc := newTesseraClient(srv.URL+"/", httpClient)
+	hash, err := tc.StoreRaw([]byte("payload"), "from-key")
+	require.NoError(t, err)
+	assert.Equal(t, key, hash.Bytes())
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("payload")), req.Payload)
+	assert.Equal(t, "from-key", req.From)
+
+	tc = newTesseraClient(srv.URL+"/nope", httpClient)
+	_, err = tc.StoreRaw([]byte("payload"), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TransportConfig tunes the http client used for each eth and tessera
+// connection. Each client gets its own transport, so MaxIdleConnsPerHost is
+// per thread.
+type TransportConfig struct {
+	MaxIdleConnsPerHost int           `mapstructure:"max-idle-conns"`
+	KeepAlive           time.Duration `mapstructure:"keep-alive"`
+	HTTP2               bool          `mapstructure:"http2"`
+
+	TLSInsecure bool   `mapstructure:"tls-insecure"`
+	TLSCACert   string `mapstructure:"tls-ca"`
+	TLSCert     string `mapstructure:"tls-cert"`
+	TLSKey      string `mapstructure:"tls-key"`
+
+	// Headers are added to every request. Each is formatted "Name: value"
+	Headers []string `mapstructure:"header"`
+}
+
+func (cfg *TransportConfig) SetDefaults() {
+	// The go default of 2 idle connections per host means high thread counts
+	// to the same host churn connections and exhaust ephemeral ports
+	cfg.MaxIdleConnsPerHost = 16
+	cfg.KeepAlive = 30 * time.Second
+	cfg.HTTP2 = true
+	cfg.TLSInsecure = false
+	cfg.TLSCACert = ""
+	cfg.TLSCert = ""
+	cfg.TLSKey = ""
+	cfg.Headers = nil
+}
+
+// NewHTTPClient creates an http.Client with a transport configured according
+// to cfg, which presents the credentials in auth.
+func (cfg *TransportConfig) NewHTTPClient(clientTimeout time.Duration, auth AuthConfig) (*http.Client, error) {
+	return cfg.NewHostHTTPClient(clientTimeout, "", auth)
+}
+
+// NewHostHTTPClient is NewHTTPClient for urls whose host name was replaced by
+// an address it resolved to (--resolvehosts). If host is set it is sent as
+// the tls server name and the Host header, so certificate verification and
+// name based routing (eg an ingress) still see the name.
+func (cfg *TransportConfig) NewHostHTTPClient(
+	clientTimeout time.Duration, host string, auth AuthConfig) (*http.Client, error) {
+
+	tlsConfig, err := cfg.tlsConfig(host)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: cfg.KeepAlive,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     cfg.HTTP2,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+		DisableKeepAlives:     cfg.KeepAlive < 0,
+	}
+	if !cfg.HTTP2 {
+		// A non nil, empty, map is how http2 is disabled for a Transport
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	headers, err := parseHeaders(cfg.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	var rt http.RoundTripper = transport
+	if len(headers) != 0 {
+		rt = &headerTransport{headers: headers, next: rt}
+	}
+	if host != "" {
+		rt = &hostTransport{host: host, next: rt}
+	}
+	if rt, err = newAuthTransport(auth, rt); err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Timeout: clientTimeout, Transport: rt}, nil
+}
+
+// tlsConfig returns the tls options, or nil for the go defaults. serverName,
+// if set, is verified instead of the host in the url.
+func (cfg *TransportConfig) tlsConfig(serverName string) (*tls.Config, error) {
+
+	if !cfg.TLSInsecure && cfg.TLSCACert == "" && cfg.TLSCert == "" && serverName == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.TLSInsecure, ServerName: serverName}
+
+	if cfg.TLSCACert != "" {
+		pem, err := ioutil.ReadFile(cfg.TLSCACert)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca cert `%s': %w", cfg.TLSCACert, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in `%s'", cfg.TLSCACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func parseHeaders(headers []string) (http.Header, error) {
+	h := http.Header{}
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("header `%s' is not formatted as 'Name: value'", header)
+		}
+		h.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return h, nil
+}
+
+// headerTransport adds fixed headers to every request
+type headerTransport struct {
+	headers http.Header
+	next    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the callers request
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	return t.next.RoundTrip(req)
+}
+
+// hostTransport sends the host name the url was resolved from as the Host
+// header
+type hostTransport struct {
+	host string
+	next http.RoundTripper
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Host = t.host
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeaders(t *testing.T) {
+
+	h, err := parseHeaders([]string{"X-Api-Key: abc", "X-Trace:1:2", "X-Api-Key:def"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", "def"}, h.Values("X-Api-Key"))
+	assert.Equal(t, "1:2", h.Get("X-Trace"))
+
+	_, err = parseHeaders([]string{"no-colon"})
+	assert.Error(t, err)
+	_, err = parseHeaders([]string{": value"})
+	assert.Error(t, err)
+}
+
+func TestNewHTTPClientHeaders(t *testing.T) {
+
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	cfg := TransportConfig{}
+	cfg.SetDefaults()
+	cfg.Headers = []string{"X-Api-Key: abc"}
+
+	c, err := cfg.NewHTTPClient(time.Second, AuthConfig{Token: "tok"})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Caller", "1")
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "abc", got.Get("X-Api-Key"))
+	assert.Equal(t, "Bearer tok", got.Get("Authorization"))
+	assert.Equal(t, "1", got.Get("X-Caller"))
+	// The callers request is not modified
+	assert.Empty(t, req.Header.Get("X-Api-Key"))
+}
+
+func TestNewHTTPClientTransport(t *testing.T) {
+
+	cfg := TransportConfig{}
+	cfg.SetDefaults()
+	cfg.MaxIdleConnsPerHost = 64
+	cfg.HTTP2 = false
+
+	c, err := cfg.NewHTTPClient(3*time.Second, AuthConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, c.Timeout)
+
+	transport := innerTransport(t, c)
+	assert.Equal(t, 64, transport.MaxIdleConnsPerHost)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.TLSNextProto)
+	assert.Nil(t, transport.TLSClientConfig)
+	assert.False(t, transport.DisableKeepAlives)
+
+	cfg.KeepAlive = -1
+	cfg.TLSInsecure = true
+	c, err = cfg.NewHTTPClient(time.Second, AuthConfig{})
+	require.NoError(t, err)
+	transport = innerTransport(t, c)
+	assert.True(t, transport.DisableKeepAlives)
+	require.NotNil(t, transport.TLSClientConfig)
+	assert.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+
+	cfg.TLSCACert = filepath.Join(t.TempDir(), "missing.pem")
+	_, err = cfg.NewHTTPClient(time.Second, AuthConfig{})
+	assert.Error(t, err)
+}
+
+func TestNewHostHTTPClient(t *testing.T) {
+
+	var gotHost string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+	}))
+	defer srv.Close()
+
+	// The test server certificate is for example.com and 127.0.0.1
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(
+		&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644))
+
+	cfg := TransportConfig{}
+	cfg.SetDefaults()
+	cfg.TLSCACert = caFile
+
+	// The url has the address example.com resolved to. The certificate is
+	// verified for, and the Host header names, example.com.
+	c, err := cfg.NewHostHTTPClient(time.Second, "example.com", AuthConfig{})
+	require.NoError(t, err)
+	resp, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "example.com", gotHost)
+
+	// The name is what the certificate is checked against
+	c, err = cfg.NewHostHTTPClient(time.Second, "other.example", AuthConfig{})
+	require.NoError(t, err)
+	_, err = c.Get(srv.URL)
+	assert.Error(t, err)
+}
+
+// innerTransport returns the http.Transport under the auth transport, which
+// always wraps it
+func innerTransport(t *testing.T, c *http.Client) *http.Transport {
+	auth, ok := c.Transport.(*authTransport)
+	require.True(t, ok)
+	transport, ok := auth.next.(*http.Transport)
+	require.True(t, ok, "no headers, so only the auth transport wraps it")
+	return transport
+}
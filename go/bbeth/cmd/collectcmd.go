@@ -7,9 +7,10 @@ package cmd
 
 import (
 	"context"
-	"fmt"
 	"path/filepath"
 
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/robinbryce/benchblock/bbeth/client"
 	"github.com/robinbryce/benchblock/bbeth/collect"
 	"github.com/robinbryce/benchblock/bbeth/root"
 	"github.com/spf13/cobra"
@@ -58,6 +59,37 @@ rate to collect blocks, also effectively the window over which the tps & tpb are
 averaged for the progress bar. ignored if dbsource is not set (set to :memory:
 if you don't want the results but do want the rate indicators)`)
 
+	f.Float64Var(
+		&cfg.TraceSample, "trace-sample", cfg.TraceSample, `
+percentage (0 to 100) of mined transactions to trace with
+debug_traceTransaction. the gas used is broken down by opcode category
+(storage, memory, call, ...), recorded in the tracegas table and summarised at
+the end. the nodes must enable the debug api. tracing is done by a worker
+with a bounded queue, sampled transactions are skipped (and counted) if it
+falls behind, so keep the sample small for high rate runs. for load, set
+trace-sample in the collect section of the config file`)
+	f.BoolVar(
+		&cfg.TxPool, "txpool", false, `
+sample txpool_status (pending and queued) at the collect rate, on every node
+for load or on the --eth node for collect. samples are recorded in the txpool
+table and the peaks reported at the end. the nodes must enable the txpool api.
+for load, set txpool in the collect section of the config file`)
+	f.BoolVar(
+		&cfg.NodeStats, "node-stats", false, `
+sample net_peerCount, eth_syncing and, where the node enables it,
+debug_metrics (memory, goroutines and disk io) at the collect rate on the
+same nodes as --txpool. samples are recorded in the nodestats table. for
+load, set node-stats in the collect section of the config file`)
+	f.BoolVar(
+		&cfg.VerifyPrivate, "verify-private", false, `
+after collection, check the private state of each contract the collected
+private transactions were sent to, on every node in --endpoints (or the --eth
+node). a node is a party if its tessera holds the transaction payloads.
+parties must have the state replayed from the successful set and add calls,
+other nodes must have none. the collection must include every transaction
+sent to the contracts, so start it before the load. results are recorded in
+the privatestate table`)
+
 	return nil
 }
 
@@ -74,7 +106,7 @@ func (r *CollectRunner) ProcessConfig() error {
 	r.cfgDir = filepath.Dir(r.vroot.ConfigFileUsed())
 	v := r.vroot.Sub(root.GetRunnerName(r))
 	if v == nil {
-		fmt.Printf("no config for collect")
+		log.Debug("no config for collect")
 		return nil
 	}
 	ReconcileOptions(r.cmd, v)
@@ -83,7 +115,16 @@ func (r *CollectRunner) ProcessConfig() error {
 
 func (r *CollectRunner) Run(cmd *cobra.Command, args []string) {
 
-	c, err := collect.NewCollector(context.Background(), r.cfgDir, r)
+	rootCfg := r.GetNamedConfig(root.ConfigName).(*root.Config)
+
+	var opts []collect.CollectorOption
+	if rootCfg.MetricsAddr != "" {
+		m := client.NewMetrics()
+		cobra.CheckErr(m.Serve(rootCfg.MetricsAddr))
+		opts = append(opts, collect.WithMetrics(m))
+	}
+
+	c, err := collect.NewCollector(context.Background(), r.cfgDir, r, opts...)
 	cobra.CheckErr(err)
 	c.Run()
 }
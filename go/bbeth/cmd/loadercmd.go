@@ -2,9 +2,9 @@ package cmd
 
 import (
 	"context"
-	"fmt"
 	"path/filepath"
 
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/robinbryce/benchblock/bbeth/client"
 	"github.com/robinbryce/benchblock/bbeth/collect"
 	"github.com/robinbryce/benchblock/bbeth/load"
@@ -58,30 +58,108 @@ func (r *LoaderRunner) AddOptions(vroot *viper.Viper) error {
 	f.Uint64VarP(
 		&cfg.GasLimit, "gaslimit", "g", cfg.GasLimit,
 		"the gaslimit to set for each transaction")
+	f.Uint64Var(
+		&cfg.GasPrice, "gas-price", cfg.GasPrice, `
+		the gas price (wei) to set for each transaction and for the deploy. the
+		default of 0 suits quorum. a non-zero price needs --deploy-key, its
+		account funds the thread accounts before the run. transactions are
+		always legacy (pre eip-1559) transactions, this build has no dynamic fee
+		(max fee and priority fee) transactions`)
+	f.BoolVar(
+		&cfg.SuggestGasPrice, "suggest-gas-price", false, `
+		if set, ask the first node for a gas price (eth_gasPrice) at startup and
+		use that instead of --gas-price. use this for upstream geth or besu
+		networks which enforce a base fee. like --gas-price, it needs
+		--deploy-key`)
+	f.Int64Var(
+		&cfg.ChainID, "chain-id", cfg.ChainID, `
+		the chain id to sign public transactions for (EIP-155). 0 (the default)
+		asks the first node (eth_chainId). -1 signs without replay protection,
+		for networks which have not enabled EIP-155`)
 	f.StringVar(
 		&cfg.PrivateFor, "privatefor", "", `
 		all transactions will be privatefor the ':' separated list of keys (quorum
-		only).  if not set, the transactions will be public`)
+		only).  if not set, the transactions will be public. several sets of
+		keys can be given, separated by ',', and transactions rotate through
+		them. eg A:B,C. a private contract is deployed from each node for each
+		set`)
+	f.StringVar(
+		&cfg.PrivateFrom, "privatefrom", cfg.PrivateFrom, `
+		',' separated tessera keys, one for each node in node order, that
+		private transactions from the node are sent from. private-from in the
+		--endpoints file takes precedence. if not set, tessera uses its default
+		key`)
+	f.StringVar(
+		&cfg.ThreadPrivateFrom, "thread-privatefrom", cfg.ThreadPrivateFrom, `
+		',' separated tessera keys, one for each thread in thread order, that
+		the threads private transactions are sent from. takes precedence over
+		--privatefrom. each key must be held by the tessera of the node the
+		thread is assigned to. empty entries use the nodes key. a thread which
+		fails over uses the key of the node it moves to`)
+	f.StringVar(
+		&cfg.PrivacyFlag, "privacy-flag", cfg.PrivacyFlag, `
+		privacy flag for private transactions: standard, party-protection or psv
+		(private state validation)`)
+	f.Float64Var(
+		&cfg.PrivateRatio, "private-ratio", cfg.PrivateRatio, `
+		interleave public and private transactions, with this fraction (0 to 1)
+		of them private. requires --privatefor. each transaction is tagged and,
+		with --dbsource, latency and throughput are reported for both and
+		recorded in the txlatency table`)
+	f.BoolVar(
+		&cfg.VerifyPrivate, "verify-private", false, `
+		after the run, check the private state of each private contract on every
+		node. parties must have all the transactions applied, nodes which are
+		not parties (by their --privatefrom and --thread-privatefrom keys) must
+		have no state. requires --dbsource: the collector records the private
+		transactions and replays the expected state from those whose private
+		receipts show they succeeded, as collect --verify-private does`)
 
 	f.BoolVar(
 		&cfg.SingleNode, "singlenode", false, "if set all clients will connect to the same node (regardless of other options)")
+	f.StringVar(
+		&cfg.Assign, "assign", cfg.Assign, `
+	how threads are assigned to nodes. one of:
+	  roundrobin     - thread i uses node i % nodes
+	  weighted       - threads are shared in proportion to the endpoint weight
+	  non-validators - round robin over the endpoints not marked validator
+	  named          - round robin over the endpoints named by --assign-nodes
+	weights, validators and names come from the --endpoints file`)
+	f.StringSliceVar(
+		&cfg.AssignNodes, "assign-nodes", cfg.AssignNodes, `
+	comma separated endpoint names to load when --assign is named`)
 
 	f.BoolVar(
 		&cfg.CheckReceipts, "check-reciepts", false, `
-	if set, threads will verify the transactions issued for each batch at the
-	end of each batch. otherwise transactions are not verified`)
+	if set, the receipt of every transaction is checked by a pool of receipt
+	pollers, concurrently with sending. the outcome of each transaction and
+	the latency until its receipt was found are reported, and with --dbsource
+	recorded for each transaction in the txreceipts table. otherwise
+	transactions are not verified`)
+	f.IntVar(
+		&cfg.ReceiptPollers, "receipt-pollers", cfg.ReceiptPollers, `
+	the number of concurrent receipt pollers for --check-reciepts. 0 (the
+	default) is one per thread`)
 	f.StringVar(
 		&cfg.TesseraEndpoint, "tessera", cfg.TesseraEndpoint, `
 	if privatefor is set, this must be the tessera endpoint to which the private
-	payload can be submitted. each thread derives a client url by adding its
-	index to the port (unless --singlenode is set)`)
+	payload can be submitted. each node derives a client url by adding its
+	index to the port. with one node, or --singlenode, the url is used as is`)
 
 	f.StringVar(
 		&cfg.StaticNodes, "staticnodes", cfg.StaticNodes, `
 	An alternative to --eth. If provided, node hosts are read from the file.
 	Its assumed to be the same format as static-nodes.json. Only the hostname
 	(or IP) field of the url is significant. The port is set seperately
-	(baseport) and must be the same for all nodes.`)
+	(baseport) and must be the same for all nodes. Entries may also be
+	http(s):// or ws(s):// urls, whose scheme is kept, or ipc socket paths`)
+	f.StringVar(
+		&cfg.TesseraAuthToken, "tessera-auth-token", cfg.TesseraAuthToken, `
+	bearer token to send to the tessera endpoints`)
+	f.StringVar(
+		&cfg.TesseraJWTSecret, "tessera-jwt-secret", cfg.TesseraJWTSecret, `
+	file containing a hex encoded 32 byte secret used to sign a fresh HS256
+	token for each tessera request`)
 	f.IntVar(
 		&cfg.BaseTesseraPort, "basetesseraport", cfg.BaseTesseraPort,
 		`The first port if --eth is used. If using --staticnodes all nodes be on this port`)
@@ -91,6 +169,28 @@ func (r *LoaderRunner) AddOptions(vroot *viper.Viper) error {
 	choice). this just tunes the receipt retry rate, ignored if
 	--check-receipts is not set`)
 
+	f.BoolVar(
+		&cfg.Presign, "presign", false, `
+	build and sign all transactions before starting, then only send them
+	(eth_sendRawTransaction) while timing. use to be sure the rate is limited
+	by the network and not by bbeth. public transactions only`)
+	f.IntVar(
+		&cfg.BatchSize, "batch-size", cfg.BatchSize, `
+	if > 1, submit transactions in json-rpc batch requests of this size. implies
+	--presign`)
+
+	f.DurationVar(
+		&cfg.HealthInterval, "health-interval", cfg.HealthInterval, `
+	if set, probe every node at this interval (eth_blockNumber, net_peerCount
+	and eth_syncing). a node is unhealthy if a probe fails, it is syncing or, in
+	a multi node network, it has no peers`)
+	f.BoolVar(
+		&cfg.Failover, "failover", false, `
+	move threads off unhealthy nodes to healthy nodes they were not already
+	using. only the nodes selected by --assign are used. implies
+	--health-interval=5s if that is not set. failovers are reported at the end
+	of the run and recorded in the failovers table of the db`)
+
 	f.BoolVarP(
 		&cfg.RunOne, "one", "o", false,
 		"loads the configuration and issues a single transaction. use for testing the config")
@@ -98,9 +198,21 @@ func (r *LoaderRunner) AddOptions(vroot *viper.Viper) error {
 	f.Uint64Var(
 		&cfg.DeployGasLimit, "deploy-gaslimit", cfg.DeployGasLimit,
 		"the gaslimit to set for deploying the contract")
+	f.BoolVar(
+		&cfg.EstimateGas, "estimate-gas", false, `
+	if set, --gaslimit and --deploy-gaslimit are replaced by eth_estimateGas
+	results. the estimate is made once for each kind of transaction at startup.
+	gas used vs the limit is reported from receipts if --check-reciepts is set`)
+	f.Float64Var(
+		&cfg.GasMultiplier, "gas-multiplier", cfg.GasMultiplier, `
+	safety multiplier applied to the --estimate-gas results`)
 	f.StringVar(
 		&cfg.DeployKey, "deploy-key", cfg.DeployKey, `the key to use to deploy the contract. (may need to be funded, if not leave unset)`,
 	)
+	f.StringVar(
+		&cfg.SaveContract, "save-contract", cfg.SaveContract, `
+	write the address of the deployed contract to this file (relative to the
+	config file directory). pass the file to --contract-address to re-use it`)
 
 	f.Int64VarP(&r.collectStartBlock, "startblock", "s", -1,
 		`first block to collect. -1 starts at the current head`)
@@ -124,9 +236,8 @@ func (r *LoaderRunner) Run(cmd *cobra.Command, args []string) {
 	var opts []load.LoaderOption
 
 	if delta := cfg.TruncateTargetTransactions(); delta != 0 {
-		fmt.Printf(
-			"adjusted target number of transactions from %d to %d\n",
-			cfg.NumTransactions+delta, cfg.NumTransactions)
+		log.Info("adjusted target number of transactions",
+			"from", cfg.NumTransactions+delta, "to", cfg.NumTransactions)
 	}
 
 	collectCfg := r.GetParent().GetNamedConfig(collect.ConfigName).(*collect.Config)
@@ -145,6 +256,13 @@ func (r *LoaderRunner) Run(cmd *cobra.Command, args []string) {
 		opts = []load.LoaderOption{load.WithProgress(pb)}
 	}
 
+	if rootCfg.MetricsAddr != "" {
+		m := client.NewMetrics()
+		cobra.CheckErr(m.Serve(rootCfg.MetricsAddr))
+		collectorOpts = append(collectorOpts, collect.WithMetrics(m))
+		opts = append(opts, load.WithMetrics(m))
+	}
+
 	// By default the collector gets the start block from the chain. It is
 	// theoretically racy to do so but I've never seen the first tx mine fast
 	// enough to be missed.
@@ -0,0 +1,88 @@
+package cmd
+
+// The preflight command checks the network is fit to run the load against. It
+// resolves the nodes exactly as the load command does, so it accepts the same
+// options and reads the same (load) section of the config file.
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/robinbryce/benchblock/bbeth/load"
+	"github.com/robinbryce/benchblock/bbeth/root"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	preflightName = "preflight"
+)
+
+type PreflightRunner struct {
+	Runnable
+	loader     Runner
+	maxHeadLag uint64
+}
+
+func (r *PreflightRunner) GetConfig() interface{} { return &r.cfg.Load }
+
+func (r *PreflightRunner) AddOptions(vroot *viper.Viper) error {
+
+	r.vroot = vroot
+
+	// Share the load command flags. The flags are the same instances, so
+	// values set on the command line are seen by both commands.
+	r.cmd.Flags().AddFlagSet(r.loader.GetCmd().PersistentFlags())
+
+	f := r.cmd.Flags()
+	f.Uint64Var(
+		&r.maxHeadLag, "max-head-lag", r.maxHeadLag, `
+	fail nodes whose head is more than this many blocks behind the highest head`)
+
+	return nil
+}
+
+func (r *PreflightRunner) ProcessConfig() error {
+
+	r.cfgDir = filepath.Dir(r.vroot.ConfigFileUsed())
+	v := r.vroot.Sub(root.GetRunnerName(r.loader))
+	if v == nil {
+		log.Debug("no config", "command", preflightName)
+		return nil
+	}
+	ReconcileOptions(r.cmd, v)
+	return nil
+}
+
+func (r *PreflightRunner) Run(cmd *cobra.Command, args []string) {
+
+	cfg := r.GetNamedConfig(load.ConfigName).(*load.Config)
+	rootCfg := r.GetNamedConfig(root.ConfigName).(*root.Config)
+
+	cobra.CheckErr(load.Preflight(context.Background(), r.cfgDir, rootCfg, cfg, r.maxHeadLag))
+}
+
+func NewPreflightCmd(parent Runner, loader Runner, cfg *Config) Runner {
+	r := &PreflightRunner{
+		Runnable: Runnable{
+			name:   preflightName,
+			parent: parent,
+			cmd: &cobra.Command{
+				Use:   preflightName,
+				Short: "check the network is ready for load",
+				Long: `
+Checks every node the load command would use: it must be reachable, agree on
+the chain id, have peers, not be syncing and agree on the head block. If
+--privatefor is set, each tessera must respond to upcheck. If --deploy-key is
+set (and --contract-address is not), the key must be able to pay for the
+deploy. Accepts the same options as load`,
+			},
+			cfg: cfg,
+		},
+		loader:     loader,
+		maxHeadLag: 5,
+	}
+	r.cmd.Run = r.Run
+	return r
+}
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/robinbryce/benchblock/bbeth/client"
 	"github.com/robinbryce/benchblock/bbeth/collect"
 	"github.com/robinbryce/benchblock/bbeth/load"
 	"github.com/robinbryce/benchblock/bbeth/root"
@@ -68,13 +70,21 @@ func (r *RootRunner) AddOptions(vroot *viper.Viper) error {
 
 	f.StringVarP(
 		&r.cfg.EthEndpoint, "eth", "e", r.cfg.EthEndpoint, `
-ethereum json rpc endpoint. each thread derives a client url by adding its index
-to the port (unless --singlenode is set)`)
+ethereum json rpc endpoint. http(s)://, ws(s):// or an ipc socket path, which
+must be absolute or end in .ipc. each thread derives a client url by adding its
+index to the port (unless --singlenode is set). all threads share an ipc socket`)
 	f.IntVar(
 		&r.cfg.BasePort, "baseport", r.cfg.BasePort,
 		`The first port if --eth is used. If using --staticnodes all nodes be on this port`)
 	f.BoolVar(
-		&r.cfg.ResolveHosts, "resolvehosts", false, "resolve target hostnames to ip addresses once at startup")
+		&r.cfg.ResolveHosts, "resolvehosts", false, `
+resolve target hostnames to ip addresses once at startup. the hostname is still
+used to verify tls certificates and is sent as the Host header (not for ws)`)
+	f.BoolVar(
+		&r.cfg.ExpandHosts, "expand-hosts", false, `
+resolve target hostnames (as --resolvehosts) and make a node of every address
+a hostname has, eg one per pod of a headless kubernetes service. each node is
+on the same port. without this a hostname with many addresses is an error`)
 	f.IntVarP(
 		&r.cfg.Retries, "retries", "c", r.cfg.Retries,
 		`
@@ -85,6 +95,74 @@ number of retries for any eth request (a geometric backoff is applied between ea
 	f.BoolVar(
 		&r.cfg.NoProgress, "no-progress", false,
 		"disables progress meter")
+	f.StringVar(
+		&r.cfg.LogLevel, "log-level", r.cfg.LogLevel, `
+diagnostic log level: trace, debug, info, warn, error or crit. per batch and
+per block lines are debug`)
+	f.StringVar(
+		&r.cfg.LogFormat, "log-format", r.cfg.LogFormat, `
+diagnostic log format: terminal, logfmt or json. the log is written to stderr,
+progress and reports to stdout`)
+	f.StringVar(
+		&r.cfg.MetricsAddr, "metrics-addr", r.cfg.MetricsAddr, `
+serve prometheus metrics on this address (eg :9090) at /metrics while load or
+collect runs. issued, mined, error counts by class and, for load, in-flight
+transactions are exported. block interval, txs per block and inclusion latency
+are exported as histograms`)
+	f.IntVar(
+		&r.cfg.MaxIdleConnsPerHost, "max-idle-conns", r.cfg.MaxIdleConnsPerHost, `
+maximum idle (keep-alive) connections to keep per host, for each client`)
+	f.DurationVar(
+		&r.cfg.KeepAlive, "keep-alive", r.cfg.KeepAlive, `
+tcp keep-alive period for eth and tessera connections. negative disables http
+keep-alives entirely`)
+	f.BoolVar(
+		&r.cfg.HTTP2, "http2", r.cfg.HTTP2, `
+attempt http/2 for https endpoints. set --http2=false to force http/1.1`)
+	f.BoolVar(
+		&r.cfg.TLSInsecure, "tls-insecure", false, `
+do not verify the server certificate for https endpoints`)
+	f.StringVar(
+		&r.cfg.TLSCACert, "tls-ca", r.cfg.TLSCACert, `
+pem file of ca certificates to verify https endpoints with`)
+	f.StringVar(
+		&r.cfg.TLSCert, "tls-cert", r.cfg.TLSCert, `
+pem file with a client certificate, for endpoints requiring mutual tls`)
+	f.StringVar(
+		&r.cfg.TLSKey, "tls-key", r.cfg.TLSKey, `
+pem file with the private key for --tls-cert`)
+	f.StringArrayVar(
+		&r.cfg.Headers, "header", r.cfg.Headers, `
+an http header, formatted 'Name: value', to add to every eth and tessera
+request. may be repeated`)
+
+	f.StringVar(
+		&r.cfg.AuthToken, "auth-token", r.cfg.AuthToken, `
+bearer token to send to the eth endpoints. if neither this nor --jwt-secret
+is set, user:password in the endpoint url is sent as basic auth`)
+	f.StringVar(
+		&r.cfg.JWTSecret, "jwt-secret", r.cfg.JWTSecret, `
+file containing a hex encoded 32 byte secret (as for geth
+--authrpc.jwtsecret). a fresh HS256 token is sent with each eth request`)
+	f.StringVar(
+		&r.cfg.Endpoints, "endpoints", r.cfg.Endpoints, `
+yaml file listing the nodes explicitly, one entry per node:
+
+  - name: node-0
+    eth: http://10.0.0.1:8545
+    tessera: http://10.0.0.1:9080
+    weight: 1
+    validator: false
+    auth-token: optional, overrides --auth-token for this node
+
+takes precedence over --eth and --staticnodes. relative paths are resolved
+against the config file directory. collect uses the first entry if --eth is
+not set`)
+	f.StringVar(
+		&r.cfg.ContractAddress, "contract-address", r.cfg.ContractAddress, `
+address of an already deployed contract, or the file it was saved to with
+load --save-contract. load uses it rather than deploying a new one. collect
+only counts transactions sent to it`)
 
 	return nil
 }
@@ -94,7 +172,7 @@ func (r *RootRunner) ProcessConfig() error {
 
 	v := r.vroot.Sub(root.GetRunnerName(r))
 	if v == nil {
-		fmt.Println("no config found")
+		log.Debug("no config found")
 		return nil
 	}
 
@@ -129,8 +207,11 @@ func NewRootCmd() *cobra.Command {
 	rr.cmd.Run = rr.Run
 
 	cmds = append(cmds, rr)
-	cmds = append(cmds, NewLoaderCmd(rr, rr.cfg))
+	loader := NewLoaderCmd(rr, rr.cfg)
+	cmds = append(cmds, loader)
 	cmds = append(cmds, NewCollectCmd(rr, rr.cfg))
+	// Must follow the loader, it shares the loader options
+	cmds = append(cmds, NewPreflightCmd(rr, loader, rr.cfg))
 
 	for i := 0; i < len(cmds); i++ {
 		rr.namedConfigs[cmds[i].GetName()] = cmds[i].GetConfig()
@@ -156,7 +237,14 @@ func NewRootCmd() *cobra.Command {
 
 		rr.vroot.SetEnvPrefix(envPrefix)
 		rr.vroot.AutomaticEnv()
-		for _, r := range cmds {
+
+		// The root config decides the log level and format, so it is
+		// reconciled first.
+		cmds[0].ProcessConfig()
+		if err := setLogHandler(rr.cfg.LogLevel, rr.cfg.LogFormat); err != nil {
+			return err
+		}
+		for _, r := range cmds[1:] {
 			r.ProcessConfig()
 		}
 		return nil
@@ -168,6 +256,17 @@ func NewRootCmd() *cobra.Command {
 	return rr.GetCmd()
 }
 
+// setLogHandler directs the root logger, which Loader and Collector default to,
+// to stderr
+func setLogHandler(level, format string) error {
+	h, err := client.NewLogHandler(os.Stderr, level, format)
+	if err != nil {
+		return err
+	}
+	log.Root().SetHandler(h)
+	return nil
+}
+
 // ReconcileOptions merges cli options and config giving priority to the
 // options.
 func ReconcileOptions(cmd *cobra.Command, v *viper.Viper) {
@@ -182,7 +281,13 @@ func ReconcileOptions(cmd *cobra.Command, v *viper.Viper) {
 		// Apply the viper config value to the flag when the flag is not set and viper has a value
 		if !f.Changed && v.IsSet(f.Name) {
 			val := v.Get(f.Name)
-			fmt.Println("from-viper", f.Name, val)
+			log.Debug("from-viper", "option", f.Name, "value", val)
+
+			// Lists in the config file can't be set via their string form
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				sv.Replace(v.GetStringSlice(f.Name))
+				return
+			}
 			cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val))
 		}
 	})
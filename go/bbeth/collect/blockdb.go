@@ -8,17 +8,29 @@ import (
 	"math/big"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/robinbryce/benchblock/bbeth/client"
 )
 
 type BlockDB struct {
-	db          *sql.DB
-	insertBlock *sql.Stmt
-	timeScale   time.Duration
+	db             *sql.DB
+	insertBlock    *sql.Stmt
+	insertFailover *sql.Stmt
+	insertPrivate  *sql.Stmt
+	insertLatency  *sql.Stmt
+	insertRevert   *sql.Stmt
+	insertReceipt  *sql.Stmt
+	insertTraceGas *sql.Stmt
+	insertTxPool   *sql.Stmt
+	insertNodeStat *sql.Stmt
+	insertMeta     *sql.Stmt
+	timeScale      time.Duration
 }
 
 const (
@@ -38,13 +50,154 @@ const (
 			blocknumber,timestamp,size,
 			gasUsed,gasLimit,txcount,extra)
 			VALUES(?,?,?,?,?,?,?)`
+
+	// threads moved off unhealthy nodes by load --failover. timestamp is unix
+	// millis
+	CreateFailoverTableStmt = `CREATE TABLE IF NOT EXISTS failovers(
+		timestamp INTEGER
+		,thread INTEGER
+		,fromNode TEXT
+		,toNode TEXT
+		,reason TEXT
+		)`
+	InsertFailoverStmt = `INSERT INTO failovers(
+			timestamp,thread,fromNode,toNode,reason)
+			VALUES(?,?,?,?,?)`
+
+	// private state of each private contract on each node, see
+	// VerifyPrivateState. expected is empty if the node is not known to be a
+	// party or not
+	CreatePrivateStateTableStmt = `CREATE TABLE IF NOT EXISTS privatestate(
+		contract TEXT
+		,node TEXT
+		,party TEXT
+		,expected TEXT
+		,actual TEXT
+		,ok INTEGER
+		)`
+	InsertPrivateStateStmt = `INSERT INTO privatestate(
+			contract,node,party,expected,actual,ok)
+			VALUES(?,?,?,?,?,?)`
+
+	// transactions tagged with a class (eg public or private) by the loader.
+	// sent is unix millis, latency is millis from sent to the collector
+	// seeing the block
+	CreateLatencyTableStmt = `CREATE TABLE IF NOT EXISTS txlatency(
+		hash TEXT
+		,class TEXT
+		,sent INTEGER
+		,blocknumber INTEGER
+		,latency INTEGER
+		)`
+	InsertLatencyStmt = `INSERT INTO txlatency(
+			hash,class,sent,blocknumber,latency)
+			VALUES(?,?,?,?,?)`
+
+	// reverted transactions and the reason, from replaying them with
+	// eth_call, see load --check-reciepts
+	CreateRevertTableStmt = `CREATE TABLE IF NOT EXISTS reverts(
+		hash TEXT
+		,node TEXT
+		,blocknumber INTEGER
+		,reason TEXT
+		)`
+	InsertRevertStmt = `INSERT INTO reverts(
+			hash,node,blocknumber,reason)
+			VALUES(?,?,?,?)`
+
+	// the outcome of every transaction whose receipt was checked, see load
+	// --check-reciepts. status is a client.TxResult name. sent is unix
+	// millis, latency is millis from sent to the receipt being found (or
+	// the poller giving up). queued is the part of latency the transaction
+	// spent waiting for a free poller. Under load, latency - queued is closer
+	// to the inclusion time. blocknumber is 0 if there is no receipt
+	CreateReceiptTableStmt = `CREATE TABLE IF NOT EXISTS txreceipts(
+		hash TEXT
+		,node TEXT
+		,status TEXT
+		,sent INTEGER
+		,blocknumber INTEGER
+		,latency INTEGER
+		,queued INTEGER
+		)`
+	InsertReceiptStmt = `INSERT INTO txreceipts(
+			hash,node,status,sent,blocknumber,latency,queued)
+			VALUES(?,?,?,?,?,?,?)`
+
+	// gas used by each opcode category for transactions sampled with
+	// --trace-sample, one row per transaction and category. The refund
+	// category is a credit: the gas used is the sum of the other categories
+	// less the refund
+	CreateTraceGasTableStmt = `CREATE TABLE IF NOT EXISTS tracegas(
+		hash TEXT
+		,blocknumber INTEGER
+		,category TEXT
+		,gas INTEGER
+		)`
+	InsertTraceGasStmt = `INSERT INTO tracegas(
+			hash,blocknumber,category,gas)
+			VALUES(?,?,?,?)`
+
+	// txpool_status sampled on each node at the collect rate with --txpool.
+	// timestamp is unix millis
+	CreateTxPoolTableStmt = `CREATE TABLE IF NOT EXISTS txpool(
+		timestamp INTEGER
+		,node TEXT
+		,pending INTEGER
+		,queued INTEGER
+		)`
+	InsertTxPoolStmt = `INSERT INTO txpool(
+			timestamp,node,pending,queued)
+			VALUES(?,?,?,?)`
+
+	// peers, sync status and process metrics sampled on each node at the
+	// collect rate with --node-stats. timestamp is unix millis. the metrics
+	// columns are NULL if the node does not provide debug_metrics
+	CreateNodeStatsTableStmt = `CREATE TABLE IF NOT EXISTS nodestats(
+		timestamp INTEGER
+		,node TEXT
+		,peers INTEGER
+		,syncing INTEGER
+		,currentBlock INTEGER
+		,highestBlock INTEGER
+		,memUsed INTEGER
+		,memHeld INTEGER
+		,goroutines INTEGER
+		,diskRead INTEGER
+		,diskWrite INTEGER
+		)`
+	InsertNodeStatsStmt = `INSERT INTO nodestats(
+			timestamp,node,peers,syncing,currentBlock,highestBlock,
+			memUsed,memHeld,goroutines,diskRead,diskWrite)
+			VALUES(?,?,?,?,?,?,?,?,?,?,?)`
+
+	// consensus data decoded from the header extra, one row per block whose
+	// extra is recognised, see DecodeBlockMeta. proposer is the raft minter
+	// for raft, the leader for rrr and, for ibft, recovered from the seal.
+	// round is NULL for ibft and the failed attempts for rrr. raftid is only
+	// set for raft. validators is the ',' separated validator set, or for rrr
+	// the endorsers
+	CreateBlockMetaTableStmt = `CREATE TABLE IF NOT EXISTS blockmeta(
+		blocknumber INTEGER UNIQUE
+		,consensus TEXT
+		,proposer TEXT
+		,round INTEGER
+		,numValidators INTEGER
+		,committedSeals INTEGER
+		,raftid INTEGER
+		,validators TEXT
+		)`
+	InsertBlockMetaStmt = `INSERT INTO blockmeta(
+			blocknumber,consensus,proposer,round,
+			numValidators,committedSeals,raftid,validators)
+			VALUES(?,?,?,?,?,?,?,?)`
 )
 
 func NewBlockDB(dataSourceName string, share bool) (*BlockDB, error) {
 
 	var err error
 
-	fmt.Printf("dbsource: %s\n", dataSourceName)
+	log.Info("opening results db", "dbsource", dataSourceName)
 
 	if dataSourceName == "" {
 		return nil, nil
@@ -82,9 +235,14 @@ func NewBlockDB(dataSourceName string, share bool) (*BlockDB, error) {
 		timeScale: time.Nanosecond,
 	}
 	if bdb.db, err = sql.Open("sqlite3", dataSourceName); err != nil {
-		fmt.Printf("failed to open: %s\n", dataSourceName)
+		log.Error("failed to open results db", "dbsource", dataSourceName)
 		return nil, err
 	}
+	// The receipt pollers, tracer, samplers and failover all write
+	// concurrently. Every new connection to ":memory:" is a new, empty,
+	// database, so the pool must never open a second one. sqlite serialises
+	// writers anyway.
+	bdb.db.SetMaxOpenConns(1)
 
 	// Create the table if it does not exist
 	s, err := bdb.db.Prepare(CreateTableStmt)
@@ -104,10 +262,75 @@ func NewBlockDB(dataSourceName string, share bool) (*BlockDB, error) {
 		return nil, err
 	}
 
+	if _, err = bdb.db.Exec(CreateFailoverTableStmt); err != nil {
+		return nil, err
+	}
+	if bdb.insertFailover, err = bdb.db.Prepare(InsertFailoverStmt); err != nil {
+		return nil, err
+	}
+
+	if _, err = bdb.db.Exec(CreatePrivateStateTableStmt); err != nil {
+		return nil, err
+	}
+	if bdb.insertPrivate, err = bdb.db.Prepare(InsertPrivateStateStmt); err != nil {
+		return nil, err
+	}
+
+	if _, err = bdb.db.Exec(CreateLatencyTableStmt); err != nil {
+		return nil, err
+	}
+	if bdb.insertLatency, err = bdb.db.Prepare(InsertLatencyStmt); err != nil {
+		return nil, err
+	}
+
+	if _, err = bdb.db.Exec(CreateRevertTableStmt); err != nil {
+		return nil, err
+	}
+	if bdb.insertRevert, err = bdb.db.Prepare(InsertRevertStmt); err != nil {
+		return nil, err
+	}
+
+	if _, err = bdb.db.Exec(CreateReceiptTableStmt); err != nil {
+		return nil, err
+	}
+	if bdb.insertReceipt, err = bdb.db.Prepare(InsertReceiptStmt); err != nil {
+		return nil, err
+	}
+
+	if _, err = bdb.db.Exec(CreateTraceGasTableStmt); err != nil {
+		return nil, err
+	}
+	if bdb.insertTraceGas, err = bdb.db.Prepare(InsertTraceGasStmt); err != nil {
+		return nil, err
+	}
+
+	if _, err = bdb.db.Exec(CreateTxPoolTableStmt); err != nil {
+		return nil, err
+	}
+	if bdb.insertTxPool, err = bdb.db.Prepare(InsertTxPoolStmt); err != nil {
+		return nil, err
+	}
+
+	if _, err = bdb.db.Exec(CreateNodeStatsTableStmt); err != nil {
+		return nil, err
+	}
+	if bdb.insertNodeStat, err = bdb.db.Prepare(InsertNodeStatsStmt); err != nil {
+		return nil, err
+	}
+
+	if _, err = bdb.db.Exec(CreateBlockMetaTableStmt); err != nil {
+		return nil, err
+	}
+	if bdb.insertMeta, err = bdb.db.Prepare(InsertBlockMetaStmt); err != nil {
+		return nil, err
+	}
+
 	return bdb, nil
 }
 
-// Insert a block record into the database. Not transactional
+// Insert a block record into the database. If the consensus data in the
+// header extra is recognised it is recorded in the blockmeta table. Not
+// transactional
 func (bdb *BlockDB) Insert(
 	block *types.Block, header *types.Header) error {
 
@@ -117,14 +340,89 @@ func (bdb *BlockDB) Insert(
 		header.GasUsed, header.GasLimit, len(block.Transactions()),
 		hex.EncodeToString(header.Extra),
 	)
+	if err != nil {
+		return err
+	}
+
+	meta := DecodeBlockMeta(header)
+	if meta == nil {
+		return nil
+	}
+	validators := make([]string, len(meta.Validators))
+	for i, v := range meta.Validators {
+		validators[i] = v.Hex()
+	}
+	_, err = bdb.insertMeta.Exec(
+		header.Number.Int64(), meta.Consensus, meta.Proposer.Hex(), meta.Round,
+		len(meta.Validators), meta.CommittedSeals, meta.RaftID, strings.Join(validators, ","))
+	return err
+}
+
+// InsertFailover records a thread moving from one node to another
+func (bdb *BlockDB) InsertFailover(t time.Time, thread int, from, to, reason string) error {
+	_, err := bdb.insertFailover.Exec(t.UnixNano()/int64(time.Millisecond), thread, from, to, reason)
+	return err
+}
+
+// InsertPrivateState records the private state of a contract on a node
+func (bdb *BlockDB) InsertPrivateState(
+	contract common.Address, node, party, expected, actual string, ok bool) error {
+	_, err := bdb.insertPrivate.Exec(contract.Hex(), node, party, expected, actual, ok)
+	return err
+}
+
+// InsertLatency records the class and latency of a mined transaction
+func (bdb *BlockDB) InsertLatency(
+	hash common.Hash, class string, sent time.Time, blockNumber int64, latency time.Duration) error {
+	_, err := bdb.insertLatency.Exec(
+		hash.Hex(), class, sent.UnixNano()/int64(time.Millisecond), blockNumber, latency.Milliseconds())
+	return err
+}
+
+// InsertReceipt records the outcome of a transaction whose receipt was
+// checked, and the time from sending it to the outcome being known
+func (bdb *BlockDB) InsertReceipt(
+	hash common.Hash, node, status string, sent time.Time, blockNumber int64, latency, queued time.Duration) error {
+	_, err := bdb.insertReceipt.Exec(
+		hash.Hex(), node, status, sent.UnixNano()/int64(time.Millisecond), blockNumber,
+		latency.Milliseconds(), queued.Milliseconds())
+	return err
+}
+
+// InsertRevert records a reverted transaction and the reason it reverted
+func (bdb *BlockDB) InsertRevert(hash common.Hash, node string, blockNumber int64, reason string) error {
+	_, err := bdb.insertRevert.Exec(hash.Hex(), node, blockNumber, reason)
+	return err
+}
+
+// InsertTraceGas records the gas a traced transaction used for one category
+// of opcode
+func (bdb *BlockDB) InsertTraceGas(hash common.Hash, blockNumber int64, category string, gas uint64) error {
+	_, err := bdb.insertTraceGas.Exec(hash.Hex(), blockNumber, category, gas)
+	return err
+}
+
+// InsertTxPool records a sample of the pending and queued transaction counts
+// on a node
+func (bdb *BlockDB) InsertTxPool(t time.Time, node string, pending, queued uint) error {
+	_, err := bdb.insertTxPool.Exec(t.UnixNano()/int64(time.Millisecond), node, pending, queued)
+	return err
+}
+
+// InsertNodeStats records a sample of a nodes peers, sync status and process
+// metrics
+func (bdb *BlockDB) InsertNodeStats(t time.Time, node string, s NodeStats) error {
+	_, err := bdb.insertNodeStat.Exec(
+		t.UnixNano()/int64(time.Millisecond), node, s.Peers, s.Syncing, s.CurrentBlock, s.HighestBlock,
+		s.MemUsed, s.MemHeld, s.Goroutines, s.DiskRead, s.DiskWrite)
 	return err
 }
 
-func GetBlocks(ethEndpoint, dbname string, dbshare bool, retries int, start, end int64) error {
+func GetBlocks(ethEndpoint, dbname string, dbshare bool, retries int, clientTimeout time.Duration, start, end int64, opts ...client.ClientOption) error {
 
 	var err error
 
-	eth, err := client.NewEthClient(ethEndpoint)
+	eth, err := client.NewEthClient(ethEndpoint, clientTimeout, opts...)
 	if err != nil {
 		return fmt.Errorf("creating eth client: %w", err)
 	}
@@ -161,11 +459,12 @@ func GetBlocks(ethEndpoint, dbname string, dbshare bool, retries int, start, end
 
 		if db != nil {
 			if err := db.Insert(block, h); err != nil {
-				println(fmt.Errorf("inserting block %v: %w", h.Number, err).Error())
+				log.Error("inserting block", "block", h.Number, "err", err)
 			}
 		}
 
-		// print out block number, sealer, endorer1 ... endorsern
+		// log the block number, the interval since the previous block and the
+		// block time
 		delta := "NaN"
 		if tprev != -1 {
 			delta = fmt.Sprintf("%d", int64(h.Time)-tprev)
@@ -174,8 +473,7 @@ func GetBlocks(ethEndpoint, dbname string, dbshare bool, retries int, start, end
 
 		s := int64(h.Time)
 		t := time.Unix(s, 0).Format(time.RFC3339)
-		fmt.Printf("%d %s %s", h.Number.Int64(), delta, t)
-		fmt.Println("")
+		log.Info("block", "number", h.Number.Int64(), "interval", delta, "time", t)
 	}
 
 	return nil
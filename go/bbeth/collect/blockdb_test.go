@@ -0,0 +1,51 @@
+package collect
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockDBConcurrentInserts(t *testing.T) {
+
+	db, err := NewBlockDB(":memory:", false)
+	require.NoError(t, err)
+	defer db.db.Close()
+
+	// An open result set holds its connection. Each writer would get its own,
+	// empty, in memory database if the pool opened another one.
+	rows, err := db.db.Query("SELECT hash FROM txreceipts")
+	require.NoError(t, err)
+
+	const writers, inserts = 12, 50
+	errs := make(chan error, writers*inserts)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < inserts; i++ {
+				hash := common.BigToHash(big.NewInt(int64(w*inserts + i)))
+				if err := db.InsertReceipt(hash, "node-0", "mined", time.Now(), 1, time.Second, 0); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, rows.Close())
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	var count int
+	require.NoError(t, db.db.QueryRow("SELECT COUNT(*) FROM txreceipts").Scan(&count))
+	assert.Equal(t, writers*inserts, count)
+}
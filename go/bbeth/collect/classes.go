@@ -0,0 +1,117 @@
+package collect
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// txClasses tracks the latency, from being sent to being seen mined by the
+// collector, of transactions tagged with a class. eg public or private.
+type txClasses struct {
+	mu      sync.Mutex
+	pending map[common.Hash]sentTx
+	classes map[string]*classStats
+	// order the classes were first seen in, for reporting
+	names []string
+}
+
+type sentTx struct {
+	class string
+	at    time.Time
+}
+
+type classStats struct {
+	sent      int
+	mined     int
+	firstSent time.Time
+	lastMined time.Time
+	latencies []time.Duration
+}
+
+func newTxClasses() *txClasses {
+	return &txClasses{pending: map[common.Hash]sentTx{}, classes: map[string]*classStats{}}
+}
+
+func (tc *txClasses) sent(hash common.Hash, class string, at time.Time) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	stats, ok := tc.classes[class]
+	if !ok {
+		stats = &classStats{firstSent: at}
+		tc.classes[class] = stats
+		tc.names = append(tc.names, class)
+	}
+	stats.sent++
+	tc.pending[hash] = sentTx{class: class, at: at}
+}
+
+// mined accounts for the tracked transactions in block, which the collector
+// saw at. It returns the class and latency of each.
+func (tc *txClasses) mined(block *types.Block, at time.Time) []minedTx {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	var mined []minedTx
+	for _, tx := range block.Transactions() {
+		sent, ok := tc.pending[tx.Hash()]
+		if !ok {
+			continue
+		}
+		delete(tc.pending, tx.Hash())
+
+		latency := at.Sub(sent.at)
+		stats := tc.classes[sent.class]
+		stats.mined++
+		stats.lastMined = at
+		stats.latencies = append(stats.latencies, latency)
+		mined = append(mined, minedTx{hash: tx.Hash(), class: sent.class, sent: sent.at, latency: latency})
+	}
+	return mined
+}
+
+type minedTx struct {
+	hash    common.Hash
+	class   string
+	sent    time.Time
+	latency time.Duration
+}
+
+func (tc *txClasses) print() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	fmt.Printf("%-10s %8s %8s %8s %10s %10s %10s\n", "class", "sent", "mined", "tps", "p50", "p95", "max")
+	for _, name := range tc.names {
+		stats := tc.classes[name]
+
+		var tps float64
+		if elapsed := stats.lastMined.Sub(stats.firstSent); stats.mined != 0 && elapsed > 0 {
+			tps = float64(stats.mined) / elapsed.Seconds()
+		}
+
+		latencies := append([]time.Duration(nil), stats.latencies...)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		fmt.Printf("%-10s %8d %8d %8.1f %10v %10v %10v\n",
+			name, stats.sent, stats.mined, tps,
+			percentile(latencies, 50), percentile(latencies, 95), percentile(latencies, 100))
+	}
+}
+
+// percentile returns the p'th percentile of the sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i].Round(time.Millisecond)
+}
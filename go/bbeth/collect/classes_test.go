@@ -0,0 +1,86 @@
+package collect
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxClasses(t *testing.T) {
+
+	tc := newTxClasses()
+	start := time.Unix(1000, 0)
+
+	txs := make([]*types.Transaction, 4)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(i), common.HexToAddress("0x1000"), new(big.Int), 60000, new(big.Int), nil)
+	}
+	tc.sent(txs[0].Hash(), "public", start)
+	tc.sent(txs[1].Hash(), "private", start.Add(time.Second))
+	tc.sent(txs[2].Hash(), "public", start.Add(2*time.Second))
+	untracked := types.NewTransaction(9, common.HexToAddress("0x2000"), new(big.Int), 60000, new(big.Int), nil)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}).WithBody(
+		[]*types.Transaction{txs[0], untracked, txs[1]}, nil)
+	mined := tc.mined(block, start.Add(3*time.Second))
+	require.Len(t, mined, 2)
+	assert.Equal(t, minedTx{hash: txs[0].Hash(), class: "public", sent: start, latency: 3 * time.Second}, mined[0])
+	assert.Equal(t, "private", mined[1].class)
+	assert.Equal(t, 2*time.Second, mined[1].latency)
+
+	// A block seen again, eg after a reorg, is not counted twice
+	assert.Empty(t, tc.mined(block, start.Add(4*time.Second)))
+
+	assert.Equal(t, []string{"public", "private"}, tc.names)
+	assert.Equal(t, 2, tc.classes["public"].sent)
+	assert.Equal(t, 1, tc.classes["public"].mined)
+	assert.Equal(t, 1, tc.classes["private"].sent)
+	assert.Equal(t, 1, tc.classes["private"].mined)
+	assert.Len(t, tc.pending, 1)
+}
+
+func TestPercentile(t *testing.T) {
+
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+
+	sorted := make([]time.Duration, 20)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	assert.Equal(t, 1*time.Millisecond, percentile(sorted, 0))
+	assert.Equal(t, 10*time.Millisecond, percentile(sorted, 50))
+	assert.Equal(t, 19*time.Millisecond, percentile(sorted, 95))
+	assert.Equal(t, 20*time.Millisecond, percentile(sorted, 100))
+
+	// Rounded to the millisecond
+	assert.Equal(t, 2*time.Millisecond, percentile([]time.Duration{1500 * time.Microsecond}, 50))
+}
+
+func TestCollectorTracking(t *testing.T) {
+
+	hash := common.HexToHash("0x1")
+
+	// Without metrics or classes nothing is tracked
+	c := &Collector{}
+	c.TxSent(hash, "public")
+	assert.Nil(t, c.sent)
+
+	// Metrics track the sent transactions for the inclusion latency, but do
+	// not enable the class report
+	c = &Collector{}
+	WithMetrics(client.NewMetrics())(c)
+	c.TxSent(hash, "public")
+	require.NotNil(t, c.sent)
+	assert.Len(t, c.sent.pending, 1)
+	assert.False(t, c.classes)
+
+	c.TrackClasses()
+	assert.True(t, c.classes)
+	assert.Len(t, c.sent.pending, 1)
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"net"
 	"net/url"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/robinbryce/benchblock/bbeth/client"
 	"github.com/robinbryce/benchblock/bbeth/root"
 )
@@ -28,6 +32,35 @@ type Collector struct {
 
 	c              *client.Client
 	collectLimiter *time.Ticker
+
+	// If set, only transactions sent to these contracts are counted as mined
+	contracts map[common.Address]bool
+
+	// If set, the latency of transactions reported with TxSent is tracked.
+	// It feeds the inclusion metric and, if classes is set, the per class
+	// report and the txlatency table.
+	sent    *txClasses
+	classes bool
+
+	metrics *client.Metrics
+	log     log.Logger
+
+	// If TraceSample is set, the gas breakdown of the traced transactions.
+	// traces queues the sampled transactions for the trace worker.
+	traceGas *traceGas
+	traces   chan traceJob
+
+	// The nodes sampled if TxPool is set, see SetNodes
+	nodeNames   []string
+	nodes       []*client.Client
+	txPoolPeaks *txPoolPeaks
+	// Set for the nodes which do not provide debug_metrics
+	noDebugMetrics []bool
+
+	// If VerifyPrivate is set, the private transactions collected and, for
+	// a standalone collect, the nodes in the endpoints file to check
+	private   *privateTxs
+	endpoints []client.Endpoint
 }
 
 type Config struct {
@@ -36,6 +69,18 @@ type Config struct {
 	EndBlock        int64
 	NumTransactions int
 	CollectRate     time.Duration `mapstructure:"collect_rate"`
+	// TraceSample is the percentage of mined transactions to trace, with
+	// debug_traceTransaction, for a gas breakdown by opcode category
+	TraceSample float64 `mapstructure:"trace-sample"`
+	// TxPool samples txpool_status on each node at the CollectRate
+	TxPool bool `mapstructure:"txpool"`
+	// NodeStats samples net_peerCount, eth_syncing and, if the node enables
+	// it, debug_metrics on each node at the CollectRate
+	NodeStats bool `mapstructure:"node-stats"`
+	// VerifyPrivate checks, after collection, the private state of the
+	// contracts the collected private transactions were sent to, on every
+	// node. See Collector.VerifyPrivate
+	VerifyPrivate bool `mapstructure:"verify-private"`
 }
 
 func NewConfigCollect() Config {
@@ -50,6 +95,10 @@ func (cfg *Config) SetDefaults() {
 	cfg.EndBlock = -1
 	cfg.NumTransactions = -1
 	cfg.CollectRate = 10 * time.Second
+	cfg.TraceSample = 0
+	cfg.TxPool = false
+	cfg.NodeStats = false
+	cfg.VerifyPrivate = false
 }
 
 type CollectorOption func(*Collector)
@@ -68,6 +117,22 @@ func WithProgress(pb *client.TransactionProgress) CollectorOption {
 	}
 }
 
+// WithLogger sets the logger, the default is the go-ethereum root logger
+func WithLogger(l log.Logger) CollectorOption {
+	return func(c *Collector) {
+		c.log = l
+	}
+}
+
+// WithMetrics records live metrics for mined transactions and blocks. The
+// inclusion latency is measured for transactions reported with TxSent.
+func WithMetrics(m *client.Metrics) CollectorOption {
+	return func(c *Collector) {
+		c.metrics = m
+		c.trackSent()
+	}
+}
+
 func NewCollector(ctx context.Context, cfgDir string, r root.Runner, opts ...CollectorOption) (*Collector, error) {
 	var err error
 
@@ -75,6 +140,7 @@ func NewCollector(ctx context.Context, cfgDir string, r root.Runner, opts ...Col
 		rootCfg:    r.GetNamedConfig(root.ConfigName).(*root.Config),
 		collectCfg: r.GetNamedConfig(ConfigName).(*Config),
 		ConfigDir:  cfgDir,
+		log:        log.Root(),
 	}
 
 	for _, opt := range opts {
@@ -82,7 +148,7 @@ func NewCollector(ctx context.Context, cfgDir string, r root.Runner, opts ...Col
 	}
 
 	if c.collectCfg.EndBlock != -1 {
-		fmt.Printf("endblock set to %d, ignoring numtransactions\n", c.collectCfg.EndBlock)
+		c.log.Info("endblock set, ignoring numtransactions", "endblock", c.collectCfg.EndBlock)
 		c.collectCfg.NumTransactions = -1
 	}
 
@@ -92,27 +158,80 @@ func NewCollector(ctx context.Context, cfgDir string, r root.Runner, opts ...Col
 		c.pb = client.NewTransactionProgress(c.collectCfg.NumTransactions)
 	}
 
+	if c.collectCfg.TraceSample < 0 || c.collectCfg.TraceSample > 100 {
+		return nil, fmt.Errorf("trace-sample must be a percentage between 0 and 100")
+	}
+	if c.collectCfg.TraceSample > 0 {
+		c.traceGas = newTraceGas()
+	}
+
 	if c.collectCfg.DBSource == "" {
 		return c, fmt.Errorf(
 			"dbsource is required by collector (try :memory: if you just want to wait for completion")
 	}
 	if c.db, err = NewBlockDB(c.collectCfg.DBSource, true /*share*/); err != nil {
-		fmt.Printf("failed creating db (%s): %v\n", c.collectCfg.DBSource, err)
+		c.log.Error("failed creating db", "dbsource", c.collectCfg.DBSource, "err", err)
 		return nil, err
 	}
 
-	if c.rootCfg.EthEndpoint == "" {
+	ethEndpoint, auth := c.rootCfg.EthEndpoint, c.rootCfg.EthAuth()
+	if c.rootCfg.Endpoints != "" && (ethEndpoint == "" || c.collectCfg.VerifyPrivate) {
+		fileName := c.rootCfg.Endpoints
+		if !filepath.IsAbs(fileName) {
+			fileName = filepath.Join(cfgDir, fileName)
+		}
+		endpoints, err := client.LoadEndpoints(fileName)
+		if err != nil {
+			return nil, err
+		}
+		// Every node is checked for private state
+		if c.collectCfg.VerifyPrivate {
+			c.endpoints = endpoints
+		}
+		// Take the first node from the endpoints file
+		if ethEndpoint == "" {
+			ethEndpoint = endpoints[0].Eth
+			auth = endpoints[0].Auth(auth)
+		}
+	}
+	if c.collectCfg.VerifyPrivate {
+		c.TrackPrivate()
+	}
+
+	if ethEndpoint == "" {
 		return nil, fmt.Errorf("ethendpoint is a required option")
 	}
 
 	c.collectLimiter = time.NewTicker(c.collectCfg.CollectRate)
 
-	qu, err := url.Parse(c.rootCfg.EthEndpoint)
+	if c.rootCfg.ContractAddress != "" {
+		address, err := client.ParseContractAddress(cfgDir, c.rootCfg.ContractAddress)
+		if err != nil {
+			return nil, err
+		}
+		c.SetContractAddress(address)
+	}
+
+	if err := client.CheckEndpoint(ethEndpoint); err != nil {
+		return nil, err
+	}
+
+	// ipc socket paths have no host or port to adjust
+	if client.IsIPCEndpoint(ethEndpoint) {
+		c.c, err = client.NewClient(ethEndpoint, "", c.rootCfg.ClientTimeout, c.clientOptions(auth, "")...)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	qu, err := url.Parse(ethEndpoint)
 	if err != nil {
 		return nil, err
 	}
 
-	quHostname, err := c.resolveHost(qu.Hostname())
+	// With ExpandHosts the first address is as good as any other
+	quHostname, err := c.rootCfg.HostResolver().ResolveOne(qu.Hostname())
 	if err != nil {
 		return nil, err
 	}
@@ -124,20 +243,240 @@ func NewCollector(ctx context.Context, cfgDir string, r root.Runner, opts ...Col
 			return nil, err
 		}
 	}
-	qu.Host = fmt.Sprintf("%s:%d", quHostname, baseQuorumPort)
-	c.c, err = client.NewClient(qu.String(), "", c.rootCfg.ClientTimeout)
+	// The name is still presented, for tls and the Host header, if the host
+	// was replaced by its address
+	var resolvedFrom string
+	if quHostname != qu.Hostname() {
+		resolvedFrom = qu.Hostname()
+	}
+	qu.Host = net.JoinHostPort(quHostname, strconv.Itoa(baseQuorumPort))
+	c.c, err = client.NewClient(qu.String(), "", c.rootCfg.ClientTimeout, c.clientOptions(auth, resolvedFrom)...)
 	if err != nil {
 		return nil, err
 	}
 	return c, nil
 }
 
+// SetContractAddress restricts the transactions counted as mined to those sent
+// to the contracts at addresses. It must be called before Collect. This is
+// what lets collect reference a --contract-address deployment: on a shared or
+// long lived network the blocks also carry unrelated transactions, and the
+// deploy itself, which would otherwise inflate the mined total.
+func (c *Collector) SetContractAddress(addresses ...common.Address) {
+	c.contracts = map[common.Address]bool{}
+	for _, address := range addresses {
+		c.log.Info("counting transactions for contract", "contract", address.Hex())
+		c.contracts[address] = true
+	}
+}
+
+// TrackClasses enables the report, by class, of the latency and throughput
+// of transactions reported with TxSent. It must be called before Collect.
+func (c *Collector) TrackClasses() {
+	c.classes = true
+	c.trackSent()
+}
+
+func (c *Collector) trackSent() {
+	if c.sent == nil {
+		c.sent = newTxClasses()
+	}
+}
+
+// TxSent tags a sent transaction with a class, eg public or private, for
+// comparison of latency and throughput by class. It does nothing unless
+// TrackClasses or WithMetrics is used.
+func (c *Collector) TxSent(hash common.Hash, class string) {
+	if c.sent == nil {
+		return
+	}
+	c.sent.sent(hash, class, time.Now())
+}
+
+// PrintClasses reports the latency and throughput of each class of tracked
+// transaction
+func (c *Collector) PrintClasses() {
+	if !c.classes {
+		return
+	}
+	c.sent.print()
+}
+
+// DB returns the results db, nil if results are not being recorded
+func (c *Collector) DB() *BlockDB {
+	return c.db
+}
+
+// RecordFailover records, in the results db, a load thread moving from one
+// node to another.
+func (c *Collector) RecordFailover(t time.Time, thread int, from, to, reason string) error {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.InsertFailover(t, thread, from, to, reason)
+}
+
+// RecordReceipt records, in the results db, the outcome of a transaction
+// whose receipt was checked, the latency from sending it and how much of that
+// it was queued for
+func (c *Collector) RecordReceipt(
+	hash common.Hash, node, status string, sent time.Time, blockNumber int64, latency, queued time.Duration) error {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.InsertReceipt(hash, node, status, sent, blockNumber, latency, queued)
+}
+
+// RecordRevert records, in the results db, a reverted transaction and the
+// reason it reverted
+func (c *Collector) RecordRevert(hash common.Hash, node string, blockNumber int64, reason string) error {
+	if c.db == nil {
+		return nil
+	}
+	return c.db.InsertRevert(hash, node, blockNumber, reason)
+}
+
+// countTransactions returns the number of transactions in the block which
+// count towards the mined total.
+func (c *Collector) countTransactions(block *types.Block) int {
+	if c.contracts == nil {
+		return len(block.Transactions())
+	}
+	var n int
+	for _, tx := range block.Transactions() {
+		if c.counts(tx) {
+			n++
+		}
+	}
+	return n
+}
+
+// counts is true if the transaction counts towards the mined total
+func (c *Collector) counts(tx *types.Transaction) bool {
+	if c.contracts == nil {
+		return true
+	}
+	to := tx.To()
+	return to != nil && c.contracts[*to]
+}
+
+// traceQueueSize bounds the transactions waiting to be traced. Sampled
+// transactions are skipped, rather than holding up collection, when it is
+// full.
+const traceQueueSize = 1024
+
+// traceJob is a transaction sampled for tracing
+type traceJob struct {
+	hash        common.Hash
+	blockNumber int64
+	gasLimit    uint64
+}
+
+// startTracer starts the worker which traces the transactions queued by
+// traceBlock. The returned func stops it, once the queue is drained.
+func (c *Collector) startTracer(ethC *client.Client, l log.Logger) func() {
+
+	c.traces = make(chan traceJob, traceQueueSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for job := range c.traces {
+			c.traceTx(ethC, job, l)
+		}
+	}()
+	return func() {
+		close(c.traces)
+		<-done
+	}
+}
+
+// traceBlock queues a TraceSample percentage of the counted transactions in
+// the block for tracing
+func (c *Collector) traceBlock(block *types.Block, l log.Logger) {
+
+	for _, tx := range block.Transactions() {
+		if !c.counts(tx) || rand.Float64()*100 >= c.collectCfg.TraceSample {
+			continue
+		}
+		select {
+		case c.traces <- traceJob{hash: tx.Hash(), blockNumber: block.Number().Int64(), gasLimit: tx.Gas()}:
+		default:
+			c.traceGas.skip()
+			l.Debug("trace queue full, skipping", "tx", tx.Hash().Hex())
+		}
+	}
+}
+
+// traceTx traces the transaction and records its gas breakdown by opcode
+// category
+func (c *Collector) traceTx(ethC *client.Client, job traceJob, l log.Logger) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.rootCfg.ClientTimeout)
+	trace, err := traceTransaction(ctx, ethC.RPC, job.hash)
+	cancel()
+	if err != nil {
+		l.Warn("tracing transaction", "tx", job.hash.Hex(), "err", err)
+		return
+	}
+
+	gas := gasByCategory(trace, job.gasLimit)
+	c.traceGas.add(gas)
+	for category, g := range gas {
+		if err = c.db.InsertTraceGas(job.hash, job.blockNumber, category, g); err != nil {
+			l.Error("inserting trace gas", "tx", job.hash.Hex(), "err", err)
+		}
+	}
+}
+
+// PrintTraceGas reports the gas breakdown, by opcode category, of the traced
+// transactions
+func (c *Collector) PrintTraceGas() {
+	if c.traceGas == nil {
+		return
+	}
+	c.traceGas.print()
+}
+
 func (c *Collector) Run() {
 
 	c.Collect(c.c, nil, fmt.Sprintf("client-%d", 0), 0)
 	if mined := c.pb.CurrentMined(); mined != -1 {
-		fmt.Printf("mined: %d\n", mined)
+		c.log.Info("collected", "mined", mined)
+	}
+	c.PrintTraceGas()
+	c.PrintTxPool()
+
+	if c.private != nil {
+		names, nodes, err := c.endpointClients()
+		if err == nil {
+			err = c.VerifyPrivate(names, nodes)
+		}
+		if err != nil {
+			c.log.Error("verifying private state", "err", err)
+		}
+	}
+}
+
+// endpointClients returns the name of each node in the endpoints file and a
+// client for it. Without an endpoints file there is only the node the
+// collector is connected to.
+func (c *Collector) endpointClients() ([]string, []*client.Client, error) {
+
+	if len(c.endpoints) == 0 {
+		return []string{client.EndpointName(c.rootCfg.EthEndpoint)}, []*client.Client{c.c}, nil
+	}
+
+	names := make([]string, len(c.endpoints))
+	nodes := make([]*client.Client, len(c.endpoints))
+	for i, ep := range c.endpoints {
+		names[i] = ep.Name
+		ethC, err := client.NewClient(ep.Eth, "", c.rootCfg.ClientTimeout, c.clientOptions(ep.Auth(c.rootCfg.EthAuth()), "")...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("connecting to %s (%s): %w", ep.Name, ep.Eth, err)
+		}
+		nodes[i] = ethC
 	}
+	return names, nodes, nil
 }
 
 func (c *Collector) Collect(ethC *client.Client, wg *sync.WaitGroup, banner string, ias int) {
@@ -151,6 +490,9 @@ func (c *Collector) Collect(ethC *client.Client, wg *sync.WaitGroup, banner stri
 	var s string
 	var lastBlock, blockNumber int64
 	var block *types.Block
+	var parentTime uint64
+
+	l := c.log.New("collector", banner)
 
 	getBlockNumber := func() (int64, error) {
 
@@ -159,21 +501,41 @@ func (c *Collector) Collect(ethC *client.Client, wg *sync.WaitGroup, banner stri
 		err = ethC.RPC.CallContext(ctx, &raw, "eth_blockNumber")
 		cancel()
 		if err != nil {
-			fmt.Printf("error calling eth_blockNumber rpc: %v\n", err)
+			l.Error("calling eth_blockNumber", "err", err)
 			return 0, err
 		}
 		if err = json.Unmarshal(raw, &s); err != nil {
-			fmt.Printf("error decoding eth_blockNumber response: %v\n", err)
+			l.Error("decoding eth_blockNumber response", "err", err)
 			return 0, err
 		}
 		num, err = strconv.ParseInt(s, 0, 64)
 		if err != nil {
-			fmt.Printf("error decoding Result field on eth_blockNumber response: %v\n", err)
+			l.Error("decoding Result field on eth_blockNumber response", "err", err)
 			return 0, err
 		}
 		return num, nil
 	}
 
+	if c.SamplesNodes() {
+		if len(c.nodes) == 0 {
+			c.SetNodes([]string{client.EndpointName(c.rootCfg.EthEndpoint)}, []*client.Client{ethC})
+		}
+		if c.collectCfg.TxPool {
+			c.txPoolPeaks = newTxPoolPeaks(c.nodeNames)
+		}
+		// Nodes which don't answer debug_metrics are not asked again
+		c.noDebugMetrics = make([]bool, len(c.nodes))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go c.sampleNodes(ctx, l)
+	}
+
+	// Tracing is slow, so it is done by a worker rather than as each block is
+	// collected
+	if c.traceGas != nil {
+		defer c.startTracer(ethC, l)()
+	}
+
 	// initialise last block number
 	lastBlock = c.collectCfg.StartBlock
 	if lastBlock == -1 {
@@ -181,7 +543,7 @@ func (c *Collector) Collect(ethC *client.Client, wg *sync.WaitGroup, banner stri
 			return
 		}
 	}
-	fmt.Printf("starting collection at block: %d\n", lastBlock)
+	l.Info("starting collection", "block", lastBlock)
 
 	for range c.collectLimiter.C {
 
@@ -194,10 +556,10 @@ func (c *Collector) Collect(ethC *client.Client, wg *sync.WaitGroup, banner stri
 		// ignoring the issue.
 		if blockNumber <= lastBlock {
 			if blockNumber < lastBlock {
-				fmt.Printf("re-org ? new head %d is < %dn", blockNumber, lastBlock)
+				l.Warn("re-org ? new head is behind the last block", "head", blockNumber, "last", lastBlock)
 			}
 			if c.rootCfg.NoProgress {
-				fmt.Printf("no more blocks since %d\n", blockNumber)
+				l.Info("no more blocks", "since", blockNumber)
 			}
 			continue
 		}
@@ -208,41 +570,77 @@ func (c *Collector) Collect(ethC *client.Client, wg *sync.WaitGroup, banner stri
 			block, err = client.GetBlockByNumber(ctx, ethC.Client, c.rootCfg.Retries, i)
 			cancel()
 			if err != nil {
-				fmt.Printf("error getting block %d: %v\n", i, err)
+				l.Error("getting block", "block", i, "err", err)
 				return
 			}
 
 			h := block.Header()
 			if err = c.db.Insert(block, h); err != nil {
-				println(fmt.Errorf("inserting block %v: %w", h.Number, err).Error())
+				l.Error("inserting block", "block", h.Number, "err", err)
 			}
 			lastBlock = i
 
+			if c.sent != nil {
+				for _, m := range c.sent.mined(block, time.Now()) {
+					c.metrics.Included(m.latency)
+					if !c.classes {
+						continue
+					}
+					if err = c.db.InsertLatency(m.hash, m.class, m.sent, i, m.latency); err != nil {
+						l.Error("inserting latency", "tx", m.hash.Hex(), "err", err)
+					}
+				}
+			}
+
 			// could actually capture and reconcile them against the accounts we created if we wanted, for now just count them.
-			ntx := len(block.Transactions())
+			ntx := c.countTransactions(block)
+
+			if c.traceGas != nil {
+				c.traceBlock(block, l)
+			}
+			if c.private != nil {
+				c.trackPrivate(block)
+			}
+
+			c.metrics.Mined(ntx)
+			if parentTime != 0 {
+				c.metrics.Block(len(block.Transactions()), blockInterval(parentTime, h.Time))
+			} else {
+				c.metrics.Block(len(block.Transactions()), 0)
+			}
+			parentTime = h.Time
 
 			if c.pb.MinedComplete(ntx) || (c.collectCfg.EndBlock == lastBlock || (lastBlock > c.collectCfg.EndBlock && c.collectCfg.EndBlock > -1)) {
-				fmt.Printf("collection complete. block %d, mined: %d\n", lastBlock, c.pb.NumMined())
+				l.Info("collection complete", "block", lastBlock, "mined", c.pb.NumMined())
 				return
 			}
 
 			if c.rootCfg.NoProgress { // NoProgress meter so print updates instead
-				fmt.Printf("block %v, txs %d, total %d\n", h.Number, ntx, c.pb.NumMined())
+				l.Info("block", "block", h.Number, "txs", ntx, "total", c.pb.NumMined())
 			}
 		}
 	}
 }
 
-func (c *Collector) resolveHost(host string) (string, error) {
-	if !c.rootCfg.ResolveHosts {
-		return host, nil
+// clientOptions returns the options for connecting to the eth endpoint. host
+// is the name the endpoint address was resolved from, if it was.
+func (c *Collector) clientOptions(auth client.AuthConfig, host string) []client.ClientOption {
+	return []client.ClientOption{
+		client.WithTransport(c.rootCfg.TransportConfig),
+		client.WithAuth(auth),
+		client.WithHost(host),
 	}
-	addrs, err := net.LookupIP(host)
-	if err != nil {
-		return "", err
+}
+
+// blockInterval returns the time between two block timestamps. Raft block
+// timestamps are in nanoseconds, the other consensus algorithms use seconds.
+func blockInterval(parent, child uint64) time.Duration {
+	if child < parent {
+		return 0
 	}
-	if len(addrs) != 1 {
-		return "", fmt.Errorf("cant resolve ambigous host. could be any of: %v", addrs)
+	d := child - parent
+	if child < 1e12 {
+		return time.Duration(d) * time.Second
 	}
-	return addrs[0].String(), nil
+	return time.Duration(d)
 }
@@ -0,0 +1,65 @@
+package collect
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountTransactions(t *testing.T) {
+
+	contract := common.HexToAddress("0x1000")
+	other := common.HexToAddress("0x2000")
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}).WithBody(
+		[]*types.Transaction{
+			types.NewTransaction(0, contract, new(big.Int), 60000, new(big.Int), nil),
+			types.NewTransaction(1, other, new(big.Int), 60000, new(big.Int), nil),
+			types.NewContractCreation(2, new(big.Int), 600000, new(big.Int), nil),
+			types.NewTransaction(3, contract, new(big.Int), 60000, new(big.Int), nil),
+		}, nil)
+
+	c := &Collector{log: log.Root()}
+	// Every transaction counts until a contract is set
+	assert.Equal(t, 4, c.countTransactions(block))
+
+	c.SetContractAddress(contract)
+	assert.Equal(t, 2, c.countTransactions(block))
+
+	c.SetContractAddress(contract, other)
+	assert.Equal(t, 3, c.countTransactions(block))
+}
+
+func TestRecordReceipt(t *testing.T) {
+
+	hash := common.HexToHash("0x1")
+	sent := time.Unix(1000, 0)
+
+	// Nothing to do without a results db
+	c := &Collector{}
+	assert.NoError(t, c.RecordReceipt(hash, "node-0", "mined", sent, 5, time.Second, 0))
+
+	db, err := NewBlockDB(":memory:", false)
+	require.NoError(t, err)
+	defer db.db.Close()
+	c = &Collector{db: db}
+	require.NoError(t, c.RecordReceipt(hash, "node-0", "mined", sent, 5, 1500*time.Millisecond, 200*time.Millisecond))
+	require.NoError(t, c.RecordReceipt(common.HexToHash("0x2"), "node-1", "not-found", sent, 0, 30*time.Second, 0))
+
+	var node, status string
+	var sentMs, block, latency, queued int64
+	require.NoError(t, db.db.QueryRow(
+		`SELECT node, status, sent, blocknumber, latency, queued FROM txreceipts WHERE hash = ?`, hash.Hex()).Scan(
+		&node, &status, &sentMs, &block, &latency, &queued))
+	assert.Equal(t, "node-0", node)
+	assert.Equal(t, "mined", status)
+	assert.Equal(t, int64(1000000), sentMs)
+	assert.Equal(t, int64(5), block)
+	assert.Equal(t, int64(1500), latency)
+	assert.Equal(t, int64(200), queued)
+}
@@ -0,0 +1,208 @@
+package collect
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Consensus types recognised from the header extra data
+const (
+	ConsensusQBFT = "qbft"
+	ConsensusIBFT = "ibft"
+	ConsensusRaft = "raft"
+	ConsensusRRR  = "rrr"
+)
+
+// raftExtraVanity is the number of unused bytes before the raft seal
+const raftExtraVanity = 32
+
+var (
+	errInvalidRaftExtra = errors.New("invalid raft header extra-data")
+	errInvalidIBFTExtra = errors.New("invalid ibft header extra-data")
+	errInvalidRRRExtra  = errors.New("invalid rrr header extra-data")
+)
+
+// BlockMeta is the consensus specific data decoded from a block header
+type BlockMeta struct {
+	Consensus string
+	// Proposer is the block proposer (ibft, qbft), minter (raft) or leader
+	// (rrr). It is the zero address if the ibft seal can't be recovered.
+	Proposer common.Address
+	// Round is the qbft round the block was committed in. For rrr it is the
+	// leader's failed attempts, the rounds which ended without a block. ibft
+	// does not record it.
+	Round *uint32
+	// Validators is the validator set for the block and CommittedSeals the
+	// number of them that committed it (ibft, qbft). For rrr, Validators are
+	// the endorsers whose endorsements the block carries, and CommittedSeals
+	// the number of endorsements.
+	Validators     []common.Address
+	CommittedSeals int
+	// RaftID is the raft id of the minter (raft)
+	RaftID *uint64
+}
+
+// raftExtraSeal is the raft minters seal. It is rlp encoded after
+// raftExtraVanity bytes of header extra.
+type raftExtraSeal struct {
+	RaftId    []byte // hex raft id, without 0x
+	Signature []byte // signature of the header hash, without the extra
+}
+
+// rrrSignedExtra is the rrr header extra, the rlp encoding of the leader's
+// signed extra data (SignedExtraData in go-rrr). There is no vanity. The
+// genesis extra is the chain initialisation data instead, it is not
+// recognised.
+type rrrSignedExtra struct {
+	Extra rrrExtraData
+	Seal  []byte
+}
+
+type rrrExtraData struct {
+	Intent  rrrIntent
+	Confirm []rrrSignedEndorsement
+	// Enrolments of new identities, which are not recorded
+	Enrol []rlp.RawValue
+	Seed  []byte
+	Proof []byte
+}
+
+// rrrIntent is the leader's intent to produce the block. The node id is the
+// keccak256 of the public key, so the last 20 bytes are the node's address.
+type rrrIntent struct {
+	ChainID        common.Hash
+	NodeID         common.Hash
+	RoundNumber    *big.Int
+	FailedAttempts uint
+	ParentHash     common.Hash
+	TxHash         common.Hash
+}
+
+type rrrSignedEndorsement struct {
+	Endorsement rrrEndorsement
+	Sig         []byte
+}
+
+type rrrEndorsement struct {
+	ChainID    common.Hash
+	IntentHash common.Hash
+	EndorserID common.Hash
+}
+
+// DecodeBlockMeta decodes the consensus data in the header extra. It returns
+// nil if the extra is not recognised.
+//
+// The qbft proposer is the header coinbase. The ibft coinbase is not set by
+// the proposer, so it is recovered from the proposer's seal instead.
+func DecodeBlockMeta(h *types.Header) *BlockMeta {
+
+	// qbft rlp encodes the whole extra, including the vanity, so it is tried
+	// first
+	if extra, err := types.ExtractQBFTExtra(h); err == nil {
+		round := extra.Round
+		return &BlockMeta{
+			Consensus: ConsensusQBFT, Proposer: h.Coinbase, Round: &round,
+			Validators: extra.Validators, CommittedSeals: len(extra.CommittedSeal)}
+	}
+
+	if extra, err := types.ExtractIstanbulExtra(h); err == nil {
+		meta := &BlockMeta{
+			Consensus: ConsensusIBFT, Validators: extra.Validators, CommittedSeals: len(extra.CommittedSeal)}
+		if proposer, err := ibftProposer(h, extra.Seal); err == nil {
+			meta.Proposer = proposer
+		}
+		return meta
+	}
+
+	if meta, err := decodeRaftMeta(h); err == nil {
+		return meta
+	}
+
+	// The rrr extra is a strict rlp list with no vanity, which none of the
+	// above decode, so it is tried last
+	if meta, err := decodeRRRMeta(h); err == nil {
+		return meta
+	}
+	return nil
+}
+
+// ibftProposer recovers the proposer from the ibft seal, as the ibft engine
+// Author does. The seal signs the keccak256 of the hash of the header with
+// the seal and committed seals removed from the extra.
+func ibftProposer(h *types.Header, seal []byte) (common.Address, error) {
+
+	filtered := types.IstanbulFilteredHeader(h, false)
+	if filtered == nil {
+		return common.Address{}, errInvalidIBFTExtra
+	}
+	enc, err := rlp.EncodeToBytes(filtered)
+	if err != nil {
+		return common.Address{}, err
+	}
+	sigHash := crypto.Keccak256(enc)
+	pub, err := crypto.SigToPub(crypto.Keccak256(sigHash), seal)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// decodeRRRMeta decodes the leader and the endorsers from the rrr extra
+func decodeRRRMeta(h *types.Header) (*BlockMeta, error) {
+
+	var extra rrrSignedExtra
+	if err := rlp.DecodeBytes(h.Extra, &extra); err != nil {
+		return nil, err
+	}
+	if len(extra.Seal) != crypto.SignatureLength {
+		return nil, errInvalidRRRExtra
+	}
+
+	intent := extra.Extra.Intent
+	attempts := uint32(intent.FailedAttempts)
+	meta := &BlockMeta{
+		Consensus: ConsensusRRR, Proposer: rrrNodeAddress(intent.NodeID), Round: &attempts,
+		Validators:     make([]common.Address, len(extra.Extra.Confirm)),
+		CommittedSeals: len(extra.Extra.Confirm)}
+	for i, e := range extra.Extra.Confirm {
+		meta.Validators[i] = rrrNodeAddress(e.Endorsement.EndorserID)
+	}
+	return meta, nil
+}
+
+// rrrNodeAddress is the address of the rrr node id
+func rrrNodeAddress(id common.Hash) common.Address {
+	return common.BytesToAddress(id[common.HashLength-common.AddressLength:])
+}
+
+// decodeRaftMeta recovers the minter from the raft seal. The minter signs
+// the header hash before the extra is set.
+func decodeRaftMeta(h *types.Header) (*BlockMeta, error) {
+
+	if len(h.Extra) <= raftExtraVanity {
+		return nil, errInvalidRaftExtra
+	}
+	var seal raftExtraSeal
+	if err := rlp.DecodeBytes(h.Extra[raftExtraVanity:], &seal); err != nil {
+		return nil, err
+	}
+
+	unsealed := types.CopyHeader(h)
+	unsealed.Extra = nil
+	pub, err := crypto.SigToPub(unsealed.Hash().Bytes(), seal.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &BlockMeta{Consensus: ConsensusRaft, Proposer: crypto.PubkeyToAddress(*pub)}
+	if id, err := strconv.ParseUint(string(seal.RaftId), 16, 64); err == nil {
+		meta.RaftID = &id
+	}
+	return meta, nil
+}
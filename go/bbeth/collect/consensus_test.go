@@ -0,0 +1,152 @@
+package collect
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeBlockMeta(t *testing.T) {
+
+	validators := []common.Address{{1}, {2}, {3}, {4}}
+	seals := [][]byte{{1}, {2}, {3}}
+
+	// qbft
+	qbft, err := rlp.EncodeToBytes(&types.QBFTExtra{
+		VanityData: make([]byte, 32), Validators: validators, Round: 2, CommittedSeal: seals})
+	require.NoError(t, err)
+	h := &types.Header{Number: big.NewInt(1), Coinbase: common.Address{2}, Extra: qbft}
+	meta := DecodeBlockMeta(h)
+	require.NotNil(t, meta)
+	assert.Equal(t, ConsensusQBFT, meta.Consensus)
+	assert.Equal(t, uint32(2), *meta.Round)
+	assert.Equal(t, validators, meta.Validators)
+	assert.Equal(t, 3, meta.CommittedSeals)
+	assert.Equal(t, common.Address{2}, meta.Proposer)
+
+	// legacy ibft, the proposer is recovered from the seal not the coinbase
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	h = &types.Header{Number: big.NewInt(1), Coinbase: common.Address{2}, Difficulty: big.NewInt(1)}
+	h.Extra = ibftExtra(t, &types.IstanbulExtra{Validators: validators, Seal: []byte{}})
+	sig, err := crypto.Sign(crypto.Keccak256(ibftSigHash(t, h)), key)
+	require.NoError(t, err)
+	// The committed seals are added after the proposer seals
+	h.Extra = ibftExtra(t, &types.IstanbulExtra{Validators: validators, Seal: sig, CommittedSeal: seals[:2]})
+	meta = DecodeBlockMeta(h)
+	require.NotNil(t, meta)
+	assert.Equal(t, ConsensusIBFT, meta.Consensus)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), meta.Proposer)
+	assert.Nil(t, meta.Round)
+	assert.Equal(t, validators, meta.Validators)
+	assert.Equal(t, 2, meta.CommittedSeals)
+
+	// an ibft seal which can't be recovered leaves the proposer unknown
+	h.Extra = ibftExtra(t, &types.IstanbulExtra{Validators: validators, Seal: make([]byte, 65), CommittedSeal: seals})
+	meta = DecodeBlockMeta(h)
+	require.NotNil(t, meta)
+	assert.Equal(t, ConsensusIBFT, meta.Consensus)
+	assert.Equal(t, common.Address{}, meta.Proposer)
+	assert.Equal(t, 3, meta.CommittedSeals)
+
+	// raft, the minter signs the header before the extra is set
+	h = &types.Header{Number: big.NewInt(2)}
+	sig, err = crypto.Sign(h.Hash().Bytes(), key)
+	require.NoError(t, err)
+	seal, err := rlp.EncodeToBytes(raftExtraSeal{RaftId: []byte("a"), Signature: sig})
+	require.NoError(t, err)
+	h.Extra = append(make([]byte, 32), seal...)
+	meta = DecodeBlockMeta(h)
+	require.NotNil(t, meta)
+	assert.Equal(t, ConsensusRaft, meta.Consensus)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), meta.Proposer)
+	assert.Equal(t, uint64(10), *meta.RaftID)
+
+	// not recognised
+	h.Extra = []byte("vanity")
+	assert.Nil(t, DecodeBlockMeta(h))
+}
+
+func TestDecodeRRRMeta(t *testing.T) {
+
+	// The node id is the keccak256 of the public key
+	var keys []*ecdsa.PrivateKey
+	var ids []common.Hash
+	for i := 0; i < 3; i++ {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		keys = append(keys, key)
+		ids = append(ids, crypto.Keccak256Hash(crypto.FromECDSAPub(&key.PublicKey)[1:]))
+	}
+	chainID := common.Hash{9}
+	sig := make([]byte, crypto.SignatureLength)
+
+	// The fixture follows the go-rrr SignedExtraData layout field by field,
+	// rather than reusing the decoder's types
+	intent := []interface{}{chainID, ids[0], big.NewInt(7), uint(2), common.Hash{1}, common.Hash{2}}
+	confirm := []interface{}{
+		[]interface{}{[]interface{}{chainID, common.Hash{3}, ids[1]}, sig},
+		[]interface{}{[]interface{}{chainID, common.Hash{3}, ids[2]}, sig},
+	}
+	enrol := []interface{}{[]interface{}{sig, common.Hash{4}, ids[2]}}
+	extra, err := rlp.EncodeToBytes([]interface{}{
+		[]interface{}{intent, confirm, enrol, []byte("seed"), []byte("proof")}, sig})
+	require.NoError(t, err)
+
+	h := &types.Header{Number: big.NewInt(7), Extra: extra}
+	meta := DecodeBlockMeta(h)
+	require.NotNil(t, meta)
+	assert.Equal(t, ConsensusRRR, meta.Consensus)
+	assert.Equal(t, crypto.PubkeyToAddress(keys[0].PublicKey), meta.Proposer)
+	assert.Equal(t, uint32(2), *meta.Round)
+	assert.Equal(t, []common.Address{
+		crypto.PubkeyToAddress(keys[1].PublicKey), crypto.PubkeyToAddress(keys[2].PublicKey)}, meta.Validators)
+	assert.Equal(t, 2, meta.CommittedSeals)
+	assert.Nil(t, meta.RaftID)
+
+	// A block with no endorsements or enrolments
+	extra, err = rlp.EncodeToBytes([]interface{}{
+		[]interface{}{intent, []interface{}{}, []interface{}{}, []byte{}, []byte{}}, sig})
+	require.NoError(t, err)
+	h.Extra = extra
+	meta = DecodeBlockMeta(h)
+	require.NotNil(t, meta)
+	assert.Empty(t, meta.Validators)
+	assert.Equal(t, 0, meta.CommittedSeals)
+
+	// The seal must be a signature
+	extra, err = rlp.EncodeToBytes([]interface{}{
+		[]interface{}{intent, confirm, enrol, []byte{}, []byte{}}, []byte{1}})
+	require.NoError(t, err)
+	h.Extra = extra
+	assert.Nil(t, DecodeBlockMeta(h))
+
+	// A missing field is not rrr
+	extra, err = rlp.EncodeToBytes([]interface{}{
+		[]interface{}{intent, confirm, enrol, []byte{}}, sig})
+	require.NoError(t, err)
+	h.Extra = extra
+	assert.Nil(t, DecodeBlockMeta(h))
+}
+
+// ibftExtra encodes the istanbul extra after 32 bytes of vanity
+func ibftExtra(t *testing.T, extra *types.IstanbulExtra) []byte {
+	enc, err := rlp.EncodeToBytes(extra)
+	require.NoError(t, err)
+	return append(make([]byte, types.IstanbulExtraVanity), enc...)
+}
+
+// ibftSigHash is the hash the ibft proposer signs (after hashing it again):
+// the header without any seals
+func ibftSigHash(t *testing.T, h *types.Header) []byte {
+	enc, err := rlp.EncodeToBytes(types.IstanbulFilteredHeader(h, false))
+	require.NoError(t, err)
+	return crypto.Keccak256(enc)
+}
@@ -0,0 +1,154 @@
+package collect
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/robinbryce/benchblock/bbeth/client"
+)
+
+// NodeStats is a sample of a nodes peers, sync status and process metrics.
+// The metrics are nil if the node does not provide debug_metrics.
+type NodeStats struct {
+	Peers        uint64
+	Syncing      bool
+	CurrentBlock uint64
+	HighestBlock uint64
+
+	MemUsed    *int64
+	MemHeld    *int64
+	Goroutines *int64
+	DiskRead   *int64
+	DiskWrite  *int64
+}
+
+// The go-ethereum process metrics recorded from debug_metrics
+const (
+	metricMemUsed    = "system/memory/used"
+	metricMemHeld    = "system/memory/held"
+	metricGoroutines = "system/cpu/goroutines"
+	metricDiskRead   = "system/disk/readbytes"
+	metricDiskWrite  = "system/disk/writebytes"
+)
+
+func (c *Collector) sampleNodeStats(l log.Logger) {
+
+	for i, ethC := range c.nodes {
+		s, err := c.nodeStats(i, ethC, l)
+		if err != nil {
+			l.Warn("sampling node stats", "node", c.nodeNames[i], "err", err)
+			continue
+		}
+		if err = c.db.InsertNodeStats(time.Now(), c.nodeNames[i], s); err != nil {
+			l.Error("inserting node stats", "node", c.nodeNames[i], "err", err)
+		}
+	}
+}
+
+func (c *Collector) nodeStats(i int, ethC *client.Client, l log.Logger) (NodeStats, error) {
+
+	var s NodeStats
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.rootCfg.ClientTimeout)
+	defer cancel()
+
+	var peers hexutil.Uint64
+	if err := ethC.RPC.CallContext(ctx, &peers, "net_peerCount"); err != nil {
+		return s, err
+	}
+	s.Peers = uint64(peers)
+
+	progress, err := ethC.SyncProgress(ctx)
+	if err != nil {
+		return s, err
+	}
+	if progress != nil {
+		s.Syncing = true
+		s.CurrentBlock = progress.CurrentBlock
+		s.HighestBlock = progress.HighestBlock
+	}
+
+	if c.noDebugMetrics[i] {
+		return s, nil
+	}
+	var raw map[string]interface{}
+	if err = ethC.RPC.CallContext(ctx, &raw, "debug_metrics", true); err != nil {
+		if isMethodNotFound(err) {
+			l.Info("debug_metrics is not available, sampling peers and sync status only",
+				"node", c.nodeNames[i], "err", err)
+			c.noDebugMetrics[i] = true
+			return s, nil
+		}
+		// Eg a timeout, it is tried again on the next sample
+		l.Warn("debug_metrics", "node", c.nodeNames[i], "err", err)
+		return s, nil
+	}
+	metrics := flattenMetrics(raw)
+	s.MemUsed = metrics.value(metricMemUsed)
+	s.MemHeld = metrics.value(metricMemHeld)
+	s.Goroutines = metrics.value(metricGoroutines)
+	s.DiskRead = metrics.value(metricDiskRead)
+	s.DiskWrite = metrics.value(metricDiskWrite)
+	return s, nil
+}
+
+// methodNotFoundCode is the json-rpc error code for an unknown method
+const methodNotFoundCode = -32601
+
+// isMethodNotFound is true if err says the node does not provide the method,
+// either by the json-rpc error code or, for nodes which use a generic code,
+// the go-ethereum message.
+func isMethodNotFound(err error) bool {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	if rpcErr.ErrorCode() == methodNotFoundCode {
+		return true
+	}
+	msg := strings.ToLower(rpcErr.Error())
+	return strings.Contains(msg, "does not exist") || strings.Contains(msg, "method not found")
+}
+
+// nodeMetrics are the numeric debug_metrics values keyed by their '/'
+// separated path
+type nodeMetrics map[string]float64
+
+// flattenMetrics flattens the nested debug_metrics result. Gauges and
+// counters are plain numbers, meters and timers are objects of numbers.
+func flattenMetrics(raw map[string]interface{}) nodeMetrics {
+	m := nodeMetrics{}
+	var flatten func(prefix string, v interface{})
+	flatten = func(prefix string, v interface{}) {
+		switch v := v.(type) {
+		case float64:
+			m[prefix] = v
+		case map[string]interface{}:
+			for k, child := range v {
+				path := k
+				if prefix != "" {
+					path = prefix + "/" + k
+				}
+				flatten(path, child)
+			}
+		}
+	}
+	flatten("", raw)
+	return m
+}
+
+// value returns the metric at path. For a meter the overall count is used.
+func (m nodeMetrics) value(path string) *int64 {
+	for _, p := range []string{path, path + "/Value", path + "/Overall", path + "/Count"} {
+		if v, ok := m[p]; ok {
+			i := int64(v)
+			return &i
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package collect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlattenMetrics(t *testing.T) {
+
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"system": {
+			"cpu": {"goroutines": 120},
+			"memory": {"used": 1024, "allocs": {"Overall": 7, "AvgRate01Min": 0.5}},
+			"disk": {"readbytes": 4096}
+		}}`), &raw))
+
+	m := flattenMetrics(raw)
+	assert.Equal(t, int64(120), *m.value(metricGoroutines))
+	assert.Equal(t, int64(1024), *m.value(metricMemUsed))
+	assert.Equal(t, int64(7), *m.value("system/memory/allocs"))
+	assert.Equal(t, int64(4096), *m.value(metricDiskRead))
+	assert.Nil(t, m.value(metricDiskWrite))
+}
+
+type testRPCError struct {
+	code int
+	msg  string
+}
+
+func (e testRPCError) Error() string  { return e.msg }
+func (e testRPCError) ErrorCode() int { return e.code }
+
+func TestIsMethodNotFound(t *testing.T) {
+
+	tests := []struct {
+		err      error
+		notFound bool
+	}{
+		{testRPCError{-32601, "Method not found"}, true},
+		{testRPCError{-32000, "the method debug_metrics does not exist/is not available"}, true},
+		{fmt.Errorf("sampling: %w", testRPCError{-32601, "not found"}), true},
+		{testRPCError{-32000, "request timed out"}, false},
+		{context.DeadlineExceeded, false},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.notFound, isMethodNotFound(tt.err), tt.err.Error())
+	}
+}
+
+// fakeStatsNode answers net_peerCount, eth_syncing and, if debug is set,
+// debug_metrics
+type fakeStatsNode struct {
+	mu sync.Mutex
+	// metricsErr, if set, fails the next debug_metrics
+	metricsErr   error
+	metricsCalls int
+}
+
+type fakeStatsNet struct{}
+
+func (fakeStatsNet) PeerCount() hexutil.Uint64 { return 3 }
+
+type fakeStatsEth struct{}
+
+func (fakeStatsEth) Syncing() (interface{}, error) { return false, nil }
+
+type fakeStatsDebug struct{ n *fakeStatsNode }
+
+func (d fakeStatsDebug) Metrics(raw bool) (map[string]interface{}, error) {
+	d.n.mu.Lock()
+	defer d.n.mu.Unlock()
+	d.n.metricsCalls++
+	if err := d.n.metricsErr; err != nil {
+		d.n.metricsErr = nil
+		return nil, err
+	}
+	return map[string]interface{}{"system": map[string]interface{}{"cpu": map[string]interface{}{"goroutines": 42}}}, nil
+}
+
+func dialStats(t *testing.T, n *fakeStatsNode, debug bool) *client.Client {
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("net", fakeStatsNet{}))
+	require.NoError(t, srv.RegisterName("eth", fakeStatsEth{}))
+	if debug {
+		require.NoError(t, srv.RegisterName("debug", fakeStatsDebug{n: n}))
+	}
+	t.Cleanup(srv.Stop)
+	c := rpc.DialInProc(srv)
+	t.Cleanup(c.Close)
+	return &client.Client{Client: ethclient.NewClient(c), RPC: c}
+}
+
+func TestNodeStatsDebugMetrics(t *testing.T) {
+
+	flaky := &fakeStatsNode{metricsErr: errors.New("request timed out")}
+	noDebug := &fakeStatsNode{}
+	c := testSampler(t, Config{NodeStats: true}, []string{"flaky", "nodebug"},
+		[]*client.Client{dialStats(t, flaky, true), dialStats(t, noDebug, false)})
+	c.noDebugMetrics = make([]bool, 2)
+
+	// A failure which is not method not found is retried on the next sample
+	s, err := c.nodeStats(0, c.nodes[0], log.Root())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), s.Peers)
+	assert.Nil(t, s.Goroutines)
+	assert.False(t, c.noDebugMetrics[0])
+
+	s, err = c.nodeStats(0, c.nodes[0], log.Root())
+	require.NoError(t, err)
+	require.NotNil(t, s.Goroutines)
+	assert.Equal(t, int64(42), *s.Goroutines)
+	assert.Equal(t, 2, flaky.metricsCalls)
+
+	// A node without the debug api is not asked again
+	s, err = c.nodeStats(1, c.nodes[1], log.Root())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), s.Peers)
+	assert.Nil(t, s.Goroutines)
+	assert.True(t, c.noDebugMetrics[1])
+
+	// Every sample is recorded, with or without the metrics
+	c.sampleNodeStats(log.Root())
+	var rows, withMetrics int
+	require.NoError(t, c.db.db.QueryRow(`SELECT COUNT(*), COUNT(goroutines) FROM nodestats`).Scan(&rows, &withMetrics))
+	assert.Equal(t, 2, rows)
+	assert.Equal(t, 1, withMetrics)
+}
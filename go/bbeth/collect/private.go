@@ -0,0 +1,294 @@
+package collect
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/robinbryce/benchblock/bbeth/client"
+)
+
+// The get/set/add interface of the contract the loader deploys. The private
+// state is replayed from the set and add calls, and read with get.
+var (
+	getSelector = crypto.Keccak256([]byte("get()"))[:4]
+	setSelector = crypto.Keccak256([]byte("set(uint256)"))[:4]
+	addSelector = crypto.Keccak256([]byte("add(uint256)"))[:4]
+)
+
+// Party says whether a node is party to a private contract
+type Party int
+
+const (
+	// PartyUnknown is for nodes whose tessera key is not known
+	PartyUnknown Party = iota
+	PartyYes
+	PartyNo
+)
+
+func (p Party) String() string {
+	switch p {
+	case PartyYes:
+		return "party"
+	case PartyNo:
+		return "non-party"
+	}
+	return "unknown"
+}
+
+// PrivateStateCheck describes the expected private state of a private
+// contract. Call is eth_call data which returns a uint256, which must be
+// Expected on the parties and zero (no contract) on the non parties.
+type PrivateStateCheck struct {
+	Address  common.Address
+	Call     []byte
+	Expected *big.Int
+	// Parties has an entry for each node
+	Parties []Party
+}
+
+// VerifyPrivateState makes the eth_call for each check on every node and
+// reports the results. If db is not nil the results are also recorded in its
+// privatestate table. It returns the number of checks which failed on one or
+// more nodes.
+func VerifyPrivateState(
+	db *BlockDB, nodes []*client.Client, names []string, checks []PrivateStateCheck, clientTimeout time.Duration) int {
+
+	var failed int
+	for _, check := range checks {
+
+		ok := true
+		for n, ethC := range nodes {
+
+			party := check.Parties[n]
+
+			ctx, cancel := context.WithTimeout(context.Background(), clientTimeout)
+			actual, err := callUint(ctx, ethC, check.Address, check.Call)
+			cancel()
+
+			var pass bool
+			switch {
+			case err != nil:
+				log.Error("reading private state", "contract", check.Address.Hex(), "node", names[n], "err", err)
+			case party == PartyYes:
+				pass = actual.Cmp(check.Expected) == 0
+			case party == PartyNo:
+				pass = actual.Sign() == 0
+			default:
+				// Nothing to compare with
+				pass = true
+			}
+			if !pass {
+				ok = false
+			}
+
+			fmt.Printf("private state %s on %s (%s): expected %v, actual %v, ok %v\n",
+				check.Address.Hex(), names[n], party, expected(party, check.Expected), actual, pass)
+
+			if db == nil {
+				continue
+			}
+			var value string
+			if actual != nil {
+				value = actual.String()
+			}
+			if err := db.InsertPrivateState(
+				check.Address, names[n], party.String(), expected(party, check.Expected), value, pass); err != nil {
+				log.Error("recording private state", "err", err)
+			}
+		}
+		if !ok {
+			failed++
+		}
+	}
+	return failed
+}
+
+func expected(party Party, value *big.Int) string {
+	switch party {
+	case PartyYes:
+		return value.String()
+	case PartyNo:
+		return "0"
+	}
+	return ""
+}
+
+// callUint makes an eth_call returning a single uint256. A contract which
+// does not exist, in the nodes view of private state, returns no data. This
+// is reported as zero.
+func callUint(ctx context.Context, ethC *client.Client, address common.Address, data []byte) (*big.Int, error) {
+	result, err := ethC.CallContract(ctx, ethereum.CallMsg{To: &address, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(result), nil
+}
+
+// privateTx is a collected private transaction. The data of a private
+// transaction is the digest of its payload in tessera.
+type privateTx struct {
+	hash   common.Hash
+	digest []byte
+}
+
+// privateTxs records, in block order, the private transactions collected for
+// each contract
+type privateTxs struct {
+	contracts []common.Address
+	txs       map[common.Address][]privateTx
+	// parties, for each node, of the contracts whose parties are known. See
+	// SetPrivateParties.
+	parties map[common.Address][]Party
+}
+
+func newPrivateTxs() *privateTxs {
+	return &privateTxs{txs: map[common.Address][]privateTx{}, parties: map[common.Address][]Party{}}
+}
+
+func (p *privateTxs) add(tx *types.Transaction) {
+	address := *tx.To()
+	if _, ok := p.txs[address]; !ok {
+		p.contracts = append(p.contracts, address)
+	}
+	p.txs[address] = append(p.txs[address], privateTx{hash: tx.Hash(), digest: tx.Data()})
+}
+
+// TrackPrivate enables VerifyPrivate by recording the private transactions,
+// to the counted contracts, as they are collected. It must be called before
+// Collect.
+func (c *Collector) TrackPrivate() {
+	if c.private == nil {
+		c.private = newPrivateTxs()
+	}
+}
+
+// SetPrivateParties says which of the nodes passed to VerifyPrivate are party
+// to the private contract at address. Without it, or for the nodes which are
+// PartyUnknown, a node is a party if its tessera holds the payload of the
+// contract's transactions.
+func (c *Collector) SetPrivateParties(address common.Address, parties []Party) {
+	c.TrackPrivate()
+	c.private.parties[address] = parties
+}
+
+// trackPrivate records the private transactions in the block which are counted
+func (c *Collector) trackPrivate(block *types.Block) {
+	for _, tx := range block.Transactions() {
+		if tx.IsPrivate() && tx.To() != nil && c.counts(tx) {
+			c.private.add(tx)
+		}
+	}
+}
+
+// VerifyPrivate checks, on each of the nodes, the private state of every
+// contract the collected private transactions were sent to. The expected
+// state is replayed, from a party, from the set and add calls whose private
+// receipts show they succeeded. The contracts start with no state, so the
+// collection must include every transaction sent to them. Contracts whose
+// state can't be replayed are reported and skipped. It does nothing unless
+// TrackPrivate is used.
+func (c *Collector) VerifyPrivate(names []string, nodes []*client.Client) error {
+
+	if c.private == nil {
+		return nil
+	}
+
+	var checks []PrivateStateCheck
+	for _, address := range c.private.contracts {
+		check, err := c.privateCheck(address, nodes)
+		if err != nil {
+			c.log.Warn("can't verify private contract", "contract", address.Hex(), "err", err)
+			continue
+		}
+		checks = append(checks, check)
+	}
+
+	if failed := VerifyPrivateState(c.db, nodes, names, checks, c.rootCfg.ClientTimeout); failed != 0 {
+		return fmt.Errorf("private state verification failed for %d contract instances", failed)
+	}
+	return nil
+}
+
+// privateCheck works out the parties to the private contract and its expected
+// state
+func (c *Collector) privateCheck(address common.Address, nodes []*client.Client) (PrivateStateCheck, error) {
+
+	txs := c.private.txs[address]
+	check := PrivateStateCheck{
+		Address: address, Call: getSelector, Expected: new(big.Int), Parties: make([]Party, len(nodes))}
+	copy(check.Parties, c.private.parties[address])
+
+	party := -1
+	for n, ethC := range nodes {
+		if check.Parties[n] == PartyUnknown {
+			payload, err := c.quorumPayload(ethC, txs[0].digest)
+			switch {
+			case err != nil:
+				c.log.Debug("no private payload", "contract", address.Hex(), "err", err)
+			case len(payload) == 0:
+				check.Parties[n] = PartyNo
+			default:
+				check.Parties[n] = PartyYes
+			}
+		}
+		if party == -1 && check.Parties[n] == PartyYes {
+			party = n
+		}
+	}
+	if party == -1 {
+		return check, fmt.Errorf("none of the nodes is a party")
+	}
+
+	for _, tx := range txs {
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.rootCfg.ClientTimeout)
+		receipt, err := nodes[party].TransactionReceipt(ctx, tx.hash)
+		cancel()
+		if err != nil {
+			return check, fmt.Errorf("private receipt for %s: %w", tx.hash.Hex(), err)
+		}
+		// Reverted transactions do not change the state
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+
+		payload, err := c.quorumPayload(nodes[party], tx.digest)
+		if err != nil {
+			return check, err
+		}
+		if len(payload) != 4+32 {
+			return check, fmt.Errorf("transaction %s is not a set or add", tx.hash.Hex())
+		}
+		x := new(big.Int).SetBytes(payload[4:])
+		switch {
+		case bytes.Equal(payload[:4], addSelector):
+			check.Expected.Add(check.Expected, x)
+		case bytes.Equal(payload[:4], setSelector):
+			check.Expected.Set(x)
+		default:
+			return check, fmt.Errorf("transaction %s is not a set or add", tx.hash.Hex())
+		}
+	}
+	return check, nil
+}
+
+// quorumPayload returns the private payload for the digest, which is empty if
+// the node's tessera is not a party to it
+func (c *Collector) quorumPayload(ethC *client.Client, digest []byte) ([]byte, error) {
+	var payload hexutil.Bytes
+	ctx, cancel := context.WithTimeout(context.Background(), c.rootCfg.ClientTimeout)
+	defer cancel()
+	if err := ethC.RPC.CallContext(ctx, &payload, "eth_getQuorumPayload", hexutil.Encode(digest)); err != nil {
+		return nil, fmt.Errorf("eth_getQuorumPayload: %w", err)
+	}
+	return payload, nil
+}
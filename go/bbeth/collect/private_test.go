@@ -0,0 +1,171 @@
+package collect
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/robinbryce/benchblock/bbeth/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeState answers eth_call with the private state the node has for each
+// contract. Contracts it has no state for return no data. Private payloads,
+// by digest, are only returned by the parties to them and the private
+// receipts only by the node the state is replayed from.
+type fakeState struct {
+	state    map[common.Address]int64
+	err      error
+	payloads map[string]hexutil.Bytes
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (f *fakeState) GetQuorumPayload(digest string) (hexutil.Bytes, error) {
+	return f.payloads[digest], nil
+}
+
+func (f *fakeState) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	return f.receipts[hash], nil
+}
+
+func (f *fakeState) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	v, ok := f.state[common.HexToAddress(args["to"].(string))]
+	if !ok {
+		return hexutil.Bytes{}, nil
+	}
+	return common.LeftPadBytes(big.NewInt(v).Bytes(), 32), nil
+}
+
+func dialState(t *testing.T, f *fakeState) *client.Client {
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", f))
+	t.Cleanup(srv.Stop)
+	c := rpc.DialInProc(srv)
+	t.Cleanup(c.Close)
+	return &client.Client{Client: ethclient.NewClient(c), RPC: c}
+}
+
+func TestVerifyPrivateState(t *testing.T) {
+
+	a := common.HexToAddress("0xa")
+	b := common.HexToAddress("0xb")
+	nodes := []*client.Client{
+		dialState(t, &fakeState{state: map[common.Address]int64{a: 4, b: 2}}),
+		dialState(t, &fakeState{state: map[common.Address]int64{a: 4}}),
+		dialState(t, &fakeState{}),
+	}
+	names := []string{"node0", "node1", "node2"}
+
+	db, err := NewBlockDB(":memory:", false)
+	require.NoError(t, err)
+	defer db.db.Close()
+
+	failed := func(checks ...PrivateStateCheck) int {
+		return VerifyPrivateState(db, nodes, names, checks, time.Second)
+	}
+
+	// a is private to nodes 0 and 1, b to node 0 only
+	ok := []PrivateStateCheck{
+		{Address: a, Expected: big.NewInt(4), Parties: []Party{PartyYes, PartyYes, PartyNo}},
+		{Address: b, Expected: big.NewInt(2), Parties: []Party{PartyYes, PartyNo, PartyUnknown}},
+	}
+	assert.Equal(t, 0, failed(ok...))
+
+	// A party with the wrong state fails, as does a non party with any
+	assert.Equal(t, 1, failed(PrivateStateCheck{
+		Address: a, Expected: big.NewInt(6), Parties: []Party{PartyYes, PartyYes, PartyNo}}))
+	assert.Equal(t, 1, failed(PrivateStateCheck{
+		Address: a, Expected: big.NewInt(4), Parties: []Party{PartyYes, PartyNo, PartyNo}}))
+
+	// Each check fails once, however many nodes disagree
+	assert.Equal(t, 1, failed(PrivateStateCheck{
+		Address: b, Expected: big.NewInt(3), Parties: []Party{PartyYes, PartyYes, PartyYes}}))
+
+	var rows, notOK int
+	require.NoError(t, db.db.QueryRow(`SELECT COUNT(*), SUM(1 - ok) FROM privatestate`).Scan(&rows, &notOK))
+	assert.Equal(t, 5*len(nodes), rows)
+	assert.Equal(t, 2+1+3, notOK)
+
+	// A node which can't be read fails the check, even if its party status is
+	// unknown
+	nodes[2] = dialState(t, &fakeState{err: errors.New("down")})
+	assert.Equal(t, 1, failed(ok[1]))
+}
+
+func TestCollectorVerifyPrivate(t *testing.T) {
+
+	a := common.HexToAddress("0xa")
+	b := common.HexToAddress("0xb")
+
+	// Private transactions carry the digest of their payload
+	payloads := map[string]hexutil.Bytes{}
+	receipts := map[common.Hash]*types.Receipt{}
+	var txs []*types.Transaction
+	send := func(to common.Address, selector []byte, x int64, status uint64) {
+		digest := common.LeftPadBytes(big.NewInt(int64(len(txs)+1)).Bytes(), 64)
+		tx := types.NewTransaction(uint64(len(txs)), to, new(big.Int), 60000, new(big.Int), digest)
+		tx.SetPrivate()
+		payloads[hexutil.Encode(digest)] = append(append([]byte{}, selector...), common.LeftPadBytes(big.NewInt(x).Bytes(), 32)...)
+		receipts[tx.Hash()] = &types.Receipt{
+			Status: status, TxHash: tx.Hash(), GasUsed: 30000, CumulativeGasUsed: 30000, Logs: []*types.Log{}, BlockNumber: big.NewInt(7)}
+		txs = append(txs, tx)
+	}
+	send(a, addSelector, 2, types.ReceiptStatusSuccessful)
+	send(a, addSelector, 2, types.ReceiptStatusFailed)
+	send(a, setSelector, 10, types.ReceiptStatusSuccessful)
+	send(a, addSelector, 3, types.ReceiptStatusSuccessful)
+	// Not a call to the get/set/add contract, so it can't be verified
+	send(b, getSelector, 0, types.ReceiptStatusSuccessful)
+	payloads[hexutil.Encode(txs[4].Data())] = getSelector
+	// Public transactions are not tracked
+	txs = append(txs, types.NewTransaction(5, a, new(big.Int), 60000, new(big.Int), nil))
+
+	db, err := NewBlockDB(":memory:", false)
+	require.NoError(t, err)
+	defer db.db.Close()
+
+	rootCfg := root.NewConfig()
+	rootCfg.ClientTimeout = time.Second
+	c := &Collector{rootCfg: &rootCfg, collectCfg: &Config{}, db: db, log: log.Root()}
+	c.TrackPrivate()
+	c.trackPrivate(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7)}).WithBody(txs, nil))
+	assert.Equal(t, []common.Address{a, b}, c.private.contracts)
+	assert.Len(t, c.private.txs[a], 4)
+
+	// Nodes 0 and 1 are parties to a, node 2 is not
+	names := []string{"node0", "node1", "node2"}
+	nodes := func(state1 int64) []*client.Client {
+		return []*client.Client{
+			dialState(t, &fakeState{state: map[common.Address]int64{a: 13}, payloads: payloads, receipts: receipts}),
+			dialState(t, &fakeState{state: map[common.Address]int64{a: state1}, payloads: payloads}),
+			dialState(t, &fakeState{}),
+		}
+	}
+
+	// 2 + 2 (reverted), set to 10, + 3
+	require.NoError(t, c.VerifyPrivate(names, nodes(13)))
+	assert.Error(t, c.VerifyPrivate(names, nodes(11)))
+
+	// Known parties take precedence over the payloads
+	c.SetPrivateParties(a, []Party{PartyYes, PartyUnknown, PartyYes})
+	assert.Error(t, c.VerifyPrivate(names, nodes(13)))
+	c.SetPrivateParties(a, []Party{PartyYes, PartyUnknown, PartyNo})
+	require.NoError(t, c.VerifyPrivate(names, nodes(13)))
+
+	// Only a was checked, on each node, each time
+	var rows int
+	require.NoError(t, db.db.QueryRow(`SELECT COUNT(*) FROM privatestate`).Scan(&rows))
+	assert.Equal(t, 4*3, rows)
+}
@@ -0,0 +1,217 @@
+package collect
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Opcode categories for the gas breakdown of traced transactions
+const (
+	GasArithmetic  = "arithmetic"
+	GasStack       = "stack"
+	GasMemory      = "memory"
+	GasStorage     = "storage"
+	GasHash        = "hash"
+	GasEnvironment = "environment"
+	GasFlow        = "flow"
+	GasLog         = "log"
+	GasCall        = "call"
+	// GasIntrinsic is the intrinsic transaction cost, charged before the
+	// first opcode runs
+	GasIntrinsic = "intrinsic"
+	// GasRefund is the refund, mostly for clearing storage with SSTORE,
+	// credited back when the transaction completes. It is not a share of the
+	// gas used: the other categories total the gas charged before the refund,
+	// the gas used is that total less GasRefund.
+	GasRefund = "refund"
+)
+
+var opcodeCategories = map[string]string{
+	"SLOAD": GasStorage, "SSTORE": GasStorage,
+
+	"MLOAD": GasMemory, "MSTORE": GasMemory, "MSTORE8": GasMemory, "MSIZE": GasMemory,
+	"CALLDATACOPY": GasMemory, "CODECOPY": GasMemory, "EXTCODECOPY": GasMemory,
+	"RETURNDATACOPY": GasMemory,
+
+	"SHA3": GasHash, "KECCAK256": GasHash,
+
+	"CALL": GasCall, "CALLCODE": GasCall, "DELEGATECALL": GasCall, "STATICCALL": GasCall,
+	"CREATE": GasCall, "CREATE2": GasCall, "SELFDESTRUCT": GasCall, "SUICIDE": GasCall,
+	"RETURN": GasCall, "REVERT": GasCall,
+
+	"POP": GasStack,
+
+	"JUMP": GasFlow, "JUMPI": GasFlow, "JUMPDEST": GasFlow, "PC": GasFlow, "STOP": GasFlow,
+
+	"ADDRESS": GasEnvironment, "BALANCE": GasEnvironment, "ORIGIN": GasEnvironment,
+	"CALLER": GasEnvironment, "CALLVALUE": GasEnvironment, "CALLDATALOAD": GasEnvironment,
+	"CALLDATASIZE": GasEnvironment, "CODESIZE": GasEnvironment, "GASPRICE": GasEnvironment,
+	"EXTCODESIZE": GasEnvironment, "EXTCODEHASH": GasEnvironment, "RETURNDATASIZE": GasEnvironment,
+	"BLOCKHASH": GasEnvironment, "COINBASE": GasEnvironment, "TIMESTAMP": GasEnvironment,
+	"NUMBER": GasEnvironment, "DIFFICULTY": GasEnvironment, "GASLIMIT": GasEnvironment,
+	"CHAINID": GasEnvironment, "SELFBALANCE": GasEnvironment, "GAS": GasEnvironment,
+}
+
+// OpcodeCategory returns the gas category for the opcode name
+func OpcodeCategory(op string) string {
+	if c, ok := opcodeCategories[op]; ok {
+		return c
+	}
+	switch {
+	case strings.HasPrefix(op, "PUSH"), strings.HasPrefix(op, "DUP"), strings.HasPrefix(op, "SWAP"):
+		return GasStack
+	case strings.HasPrefix(op, "LOG"):
+		return GasLog
+	}
+	// Arithmetic, comparison and bitwise operations
+	return GasArithmetic
+}
+
+// structLog is the subset of the debug_traceTransaction struct logger output
+// needed for the gas breakdown
+type structLog struct {
+	Op      string `json:"op"`
+	Gas     uint64 `json:"gas"`
+	GasCost uint64 `json:"gasCost"`
+	Depth   int    `json:"depth"`
+}
+
+type traceResult struct {
+	Gas        uint64      `json:"gas"`
+	Failed     bool        `json:"failed"`
+	StructLogs []structLog `json:"structLogs"`
+}
+
+// traceTransaction fetches the struct logs for the transaction. Storage,
+// memory and stack are disabled, only the opcodes and gas are wanted.
+func traceTransaction(ctx context.Context, ethRPC *rpc.Client, hash common.Hash) (*traceResult, error) {
+
+	var result traceResult
+	err := ethRPC.CallContext(ctx, &result, "debug_traceTransaction", hash, map[string]interface{}{
+		"disableStorage": true, "disableMemory": true, "disableStack": true})
+	if err != nil {
+		return nil, fmt.Errorf("debug_traceTransaction %s: %w", hash.Hex(), err)
+	}
+	return &result, nil
+}
+
+// gasByCategory totals the gas used by each category of opcode in the trace.
+// The cost the struct logger reports for a call includes the gas it forwards.
+// If the callee runs code, the next step is deeper and starts with the
+// forwarded gas, that is removed so that the gas used in the callee is only
+// counted for the callee's own opcodes. If it does not (an account without
+// code, a precompile, or a call which failed before starting) the next step is
+// at the same depth and the unused gas has already been returned, so the cost
+// is the difference in the gas available.
+//
+// The struct logger does not report the refund counter, so the intrinsic cost
+// is taken from gasLimit, the transaction's gas limit, less the gas available
+// to the first opcode. Any of the gas charged which the trace does not count
+// as used was refunded, and is reported as GasRefund rather than taken from
+// the category that earned it.
+func gasByCategory(trace *traceResult, gasLimit uint64) map[string]uint64 {
+
+	gas := map[string]uint64{}
+
+	var total uint64
+	logs := trace.StructLogs
+	for i, l := range logs {
+		cost := l.GasCost
+		if i+1 < len(logs) {
+			next := logs[i+1]
+			switch {
+			case next.Depth > l.Depth && next.Gas <= cost:
+				cost -= next.Gas
+			case next.Depth == l.Depth && next.Gas <= l.Gas && l.Gas-next.Gas < cost:
+				cost = l.Gas - next.Gas
+			}
+		}
+		gas[OpcodeCategory(l.Op)] += cost
+		total += cost
+	}
+
+	intrinsic := trace.Gas
+	if len(logs) != 0 && gasLimit >= logs[0].Gas {
+		intrinsic = gasLimit - logs[0].Gas
+	}
+	if intrinsic != 0 {
+		gas[GasIntrinsic] = intrinsic
+	}
+	if charged := intrinsic + total; charged > trace.Gas {
+		gas[GasRefund] = charged - trace.Gas
+	}
+	return gas
+}
+
+// traceGas accumulates the gas breakdown of the sampled transactions. It is
+// safe for concurrent use.
+type traceGas struct {
+	mu     sync.Mutex
+	traced int
+	// skipped counts sampled transactions not traced because the queue was
+	// full
+	skipped int
+	gas     map[string]uint64
+}
+
+func newTraceGas() *traceGas {
+	return &traceGas{gas: map[string]uint64{}}
+}
+
+func (t *traceGas) add(gas map[string]uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.traced++
+	for category, g := range gas {
+		t.gas[category] += g
+	}
+}
+
+func (t *traceGas) skip() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.skipped++
+}
+
+// print writes the total and mean gas for each category, largest first, then
+// the refund. The shares are of the gas charged before the refund.
+func (t *traceGas) print() {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.skipped != 0 {
+		fmt.Printf("trace queue full, sampled transactions skipped: %d\n", t.skipped)
+	}
+	if t.traced == 0 {
+		return
+	}
+
+	var total uint64
+	categories := make([]string, 0, len(t.gas))
+	for category, g := range t.gas {
+		if category == GasRefund {
+			continue
+		}
+		categories = append(categories, category)
+		total += g
+	}
+	sort.Slice(categories, func(i, j int) bool { return t.gas[categories[i]] > t.gas[categories[j]] })
+
+	fmt.Printf("traced transactions: %d\n", t.traced)
+	fmt.Printf("%-12s %12s %10s %7s\n", "category", "gas", "mean", "share")
+	for _, category := range categories {
+		g := t.gas[category]
+		fmt.Printf("%-12s %12d %10d %6.1f%%\n",
+			category, g, g/uint64(t.traced), 100.0*float64(g)/float64(total))
+	}
+	if g := t.gas[GasRefund]; g != 0 {
+		fmt.Printf("%-12s %12d %10d\n", GasRefund, g, g/uint64(t.traced))
+	}
+}
@@ -0,0 +1,165 @@
+package collect
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/robinbryce/benchblock/bbeth/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGasByCategory(t *testing.T) {
+
+	trace := &traceResult{
+		Gas: 50000,
+		StructLogs: []structLog{
+			{Op: "PUSH1", Gas: 30000, GasCost: 3, Depth: 1},
+			{Op: "SLOAD", Gas: 29997, GasCost: 800, Depth: 1},
+			// The call forwards 20000 to the callee
+			{Op: "CALL", Gas: 29197, GasCost: 20700, Depth: 1},
+			{Op: "SSTORE", Gas: 20000, GasCost: 5000, Depth: 2},
+			{Op: "STOP", Gas: 15000, GasCost: 0, Depth: 2},
+			{Op: "LOG1", Gas: 23497, GasCost: 1000, Depth: 1},
+		},
+	}
+
+	// 42497 intrinsic, charged before the first step
+	gas := gasByCategory(trace, 72497)
+	assert.Equal(t, uint64(3), gas[GasStack])
+	assert.Equal(t, uint64(5800), gas[GasStorage])
+	assert.Equal(t, uint64(700), gas[GasCall])
+	assert.Equal(t, uint64(1000), gas[GasLog])
+	assert.Equal(t, uint64(50000-3-5800-700-1000), gas[GasIntrinsic])
+	assert.NotContains(t, gas, GasRefund)
+
+	assert.Equal(t, GasArithmetic, OpcodeCategory("ADD"))
+	assert.Equal(t, GasStack, OpcodeCategory("DUP3"))
+	assert.Equal(t, GasHash, OpcodeCategory("SHA3"))
+}
+
+func TestGasByCategoryNoCallee(t *testing.T) {
+
+	// The calls forward 29000, the callees run no code so the unused gas is
+	// back before the next step
+	trace := func(callCost uint64) *traceResult {
+		return &traceResult{
+			Gas: 21000 + 3 + callCost,
+			StructLogs: []structLog{
+				{Op: "PUSH1", Gas: 30000, GasCost: 3, Depth: 1},
+				{Op: "CALL", Gas: 29997, GasCost: 29700, Depth: 1},
+				{Op: "STOP", Gas: 29997 - callCost, GasCost: 0, Depth: 1},
+			},
+		}
+	}
+
+	// An account without code
+	gas := gasByCategory(trace(700), 51000)
+	assert.Equal(t, uint64(700), gas[GasCall])
+	assert.Equal(t, uint64(21000), gas[GasIntrinsic])
+
+	// A precompile, its cost is part of the call
+	gas = gasByCategory(trace(772), 51000)
+	assert.Equal(t, uint64(772), gas[GasCall])
+	assert.Equal(t, uint64(21000), gas[GasIntrinsic])
+}
+
+func TestGasByCategoryRefund(t *testing.T) {
+
+	// Clearing a slot costs 5000 and earns a 15000 refund, capped at half the
+	// 26006 charged
+	trace := &traceResult{
+		Gas: 26006 - 13003,
+		StructLogs: []structLog{
+			{Op: "PUSH1", Gas: 39000, GasCost: 3, Depth: 1},
+			{Op: "PUSH1", Gas: 38997, GasCost: 3, Depth: 1},
+			{Op: "SSTORE", Gas: 38994, GasCost: 5000, Depth: 1},
+			{Op: "STOP", Gas: 33994, GasCost: 0, Depth: 1},
+		},
+	}
+
+	gas := gasByCategory(trace, 60000)
+	assert.Equal(t, uint64(5000), gas[GasStorage])
+	assert.Equal(t, uint64(6), gas[GasStack])
+	assert.Equal(t, uint64(21000), gas[GasIntrinsic])
+	assert.Equal(t, uint64(13003), gas[GasRefund])
+
+	// The categories total the gas charged, less the refund that is the gas
+	// used
+	var charged uint64
+	for category, g := range gas {
+		if category != GasRefund {
+			charged += g
+		}
+	}
+	assert.Equal(t, trace.Gas, charged-gas[GasRefund])
+
+	// Without code to run, all the gas used is intrinsic
+	gas = gasByCategory(&traceResult{Gas: 21000}, 60000)
+	assert.Equal(t, map[string]uint64{GasIntrinsic: 21000}, gas)
+}
+
+// fakeDebug answers debug_traceTransaction with the same trace for every
+// transaction
+type fakeDebug struct {
+	mu     sync.Mutex
+	traced []common.Hash
+}
+
+func (f *fakeDebug) TraceTransaction(hash common.Hash, config map[string]interface{}) (*traceResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.traced = append(f.traced, hash)
+	return &traceResult{Gas: 21003, StructLogs: []structLog{{Op: "PUSH1", Gas: 39000, GasCost: 3, Depth: 1}}}, nil
+}
+
+func TestTraceWorker(t *testing.T) {
+
+	debug := &fakeDebug{}
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("debug", debug))
+	defer srv.Stop()
+	rpcC := rpc.DialInProc(srv)
+	defer rpcC.Close()
+	ethC := &client.Client{Client: ethclient.NewClient(rpcC), RPC: rpcC}
+
+	db, err := NewBlockDB(":memory:", false)
+	require.NoError(t, err)
+	defer db.db.Close()
+
+	rootCfg := root.NewConfig()
+	c := &Collector{
+		rootCfg: &rootCfg, collectCfg: &Config{TraceSample: 100}, db: db, log: log.Root(), traceGas: newTraceGas()}
+
+	txs := make([]*types.Transaction, 3)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(i), common.HexToAddress("0x1000"), new(big.Int), 60000, new(big.Int), nil)
+	}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7)}).WithBody(txs, nil)
+
+	// Stopping waits for the queued transactions to be traced
+	stop := c.startTracer(ethC, log.Root())
+	c.traceBlock(block, log.Root())
+	stop()
+	assert.ElementsMatch(t, []common.Hash{txs[0].Hash(), txs[1].Hash(), txs[2].Hash()}, debug.traced)
+	assert.Equal(t, 3, c.traceGas.traced)
+	assert.Equal(t, uint64(3*21000), c.traceGas.gas[GasIntrinsic])
+
+	var rows int
+	require.NoError(t, db.db.QueryRow(`SELECT COUNT(*) FROM tracegas WHERE blocknumber = 7`).Scan(&rows))
+	assert.Equal(t, 3*2, rows)
+
+	// Sampled transactions are skipped, rather than blocking collection, when
+	// the queue is full
+	c.traces = make(chan traceJob, 1)
+	c.traceBlock(block, log.Root())
+	assert.Len(t, c.traces, 1)
+	assert.Equal(t, 2, c.traceGas.skipped)
+}
@@ -0,0 +1,114 @@
+package collect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/robinbryce/benchblock/bbeth/client"
+)
+
+// txPoolStatus is the txpool_status result
+type txPoolStatus struct {
+	Pending hexutil.Uint `json:"pending"`
+	Queued  hexutil.Uint `json:"queued"`
+}
+
+// txPoolPeaks tracks the largest pending and queued counts seen on each node
+type txPoolPeaks struct {
+	mu      sync.Mutex
+	names   []string
+	pending []uint
+	queued  []uint
+}
+
+// SamplesNodes is true if TxPool or NodeStats is configured, so the nodes to
+// sample are wanted
+func (c *Collector) SamplesNodes() bool {
+	return c.collectCfg.TxPool || c.collectCfg.NodeStats
+}
+
+// SetNodes sets the nodes sampled if TxPool or NodeStats is configured.
+// Without it only the node the collector is connected to is sampled, named
+// by its url. It must be called before Collect.
+func (c *Collector) SetNodes(names []string, nodes []*client.Client) {
+	c.nodeNames = names
+	c.nodes = nodes
+}
+
+// sampleNodes samples every node at the CollectRate until ctx is done
+func (c *Collector) sampleNodes(ctx context.Context, l log.Logger) {
+
+	ticker := time.NewTicker(c.collectCfg.CollectRate)
+	defer ticker.Stop()
+
+	for {
+		if c.collectCfg.TxPool {
+			c.sampleTxPool(l)
+		}
+		if c.collectCfg.NodeStats {
+			c.sampleNodeStats(l)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Collector) sampleTxPool(l log.Logger) {
+
+	for i, ethC := range c.nodes {
+
+		var status txPoolStatus
+		ctx, cancel := context.WithTimeout(context.Background(), c.rootCfg.ClientTimeout)
+		err := ethC.RPC.CallContext(ctx, &status, "txpool_status")
+		cancel()
+		if err != nil {
+			l.Warn("txpool_status", "node", c.nodeNames[i], "err", err)
+			continue
+		}
+
+		c.txPoolPeaks.update(i, uint(status.Pending), uint(status.Queued))
+		if err = c.db.InsertTxPool(time.Now(), c.nodeNames[i], uint(status.Pending), uint(status.Queued)); err != nil {
+			l.Error("inserting txpool status", "node", c.nodeNames[i], "err", err)
+		}
+	}
+}
+
+func newTxPoolPeaks(names []string) *txPoolPeaks {
+	return &txPoolPeaks{names: names, pending: make([]uint, len(names)), queued: make([]uint, len(names))}
+}
+
+func (p *txPoolPeaks) update(node int, pending, queued uint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pending > p.pending[node] {
+		p.pending[node] = pending
+	}
+	if queued > p.queued[node] {
+		p.queued[node] = queued
+	}
+}
+
+func (p *txPoolPeaks) print() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Printf("%-16s %12s %12s\n", "txpool peak", "pending", "queued")
+	for i, name := range p.names {
+		fmt.Printf("%-16s %12d %12d\n", name, p.pending[i], p.queued[i])
+	}
+}
+
+// PrintTxPool reports the largest pending and queued transaction counts seen
+// on each node
+func (c *Collector) PrintTxPool() {
+	if c.txPoolPeaks == nil {
+		return
+	}
+	c.txPoolPeaks.print()
+}
@@ -0,0 +1,126 @@
+package collect
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/robinbryce/benchblock/bbeth/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxPoolPeaks(t *testing.T) {
+
+	p := newTxPoolPeaks([]string{"node0", "node1"})
+	p.update(0, 10, 1)
+	p.update(0, 5, 3)
+	p.update(1, 7, 0)
+	p.update(0, 12, 2)
+
+	assert.Equal(t, []uint{12, 7}, p.pending)
+	assert.Equal(t, []uint{3, 0}, p.queued)
+}
+
+// fakeTxPool answers txpool_status with each of statuses in turn, then the
+// last one. With no statuses it fails.
+type fakeTxPool struct {
+	mu       sync.Mutex
+	statuses []txPoolStatus
+	calls    int
+}
+
+func (f *fakeTxPool) Status() (*txPoolStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if len(f.statuses) == 0 {
+		return nil, errors.New("the method txpool_status does not exist/is not available")
+	}
+	s := f.statuses[0]
+	if len(f.statuses) > 1 {
+		f.statuses = f.statuses[1:]
+	}
+	return &s, nil
+}
+
+func dialTxPool(t *testing.T, f *fakeTxPool) *client.Client {
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("txpool", f))
+	t.Cleanup(srv.Stop)
+	c := rpc.DialInProc(srv)
+	t.Cleanup(c.Close)
+	return &client.Client{Client: ethclient.NewClient(c), RPC: c}
+}
+
+// testSampler is a collector which samples the nodes every millisecond
+func testSampler(t *testing.T, cfg Config, names []string, nodes []*client.Client) *Collector {
+
+	db, err := NewBlockDB(":memory:", false)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.db.Close() })
+
+	rootCfg := root.NewConfig()
+	cfg.CollectRate = time.Millisecond
+	c := &Collector{rootCfg: &rootCfg, collectCfg: &cfg, db: db, log: log.Root()}
+	c.SetNodes(names, nodes)
+	return c
+}
+
+func TestSampleTxPool(t *testing.T) {
+
+	busy := &fakeTxPool{statuses: []txPoolStatus{{Pending: 5, Queued: 1}, {Pending: 9, Queued: 0}, {Pending: 2}}}
+	broken := &fakeTxPool{}
+	names := []string{"busy", "broken"}
+	c := testSampler(t, Config{TxPool: true}, names, []*client.Client{dialTxPool(t, busy), dialTxPool(t, broken)})
+	assert.True(t, c.SamplesNodes())
+	c.txPoolPeaks = newTxPoolPeaks(names)
+
+	// A node which fails is skipped, the others are still sampled
+	for i := 0; i < 3; i++ {
+		c.sampleTxPool(log.Root())
+	}
+	assert.Equal(t, []uint{9, 0}, c.txPoolPeaks.pending)
+	assert.Equal(t, []uint{1, 0}, c.txPoolPeaks.queued)
+	assert.Equal(t, 3, broken.calls)
+
+	var rows int
+	require.NoError(t, c.db.db.QueryRow(`SELECT COUNT(*) FROM txpool WHERE node = 'busy'`).Scan(&rows))
+	assert.Equal(t, 3, rows)
+	require.NoError(t, c.db.db.QueryRow(`SELECT COUNT(*) FROM txpool WHERE node = 'broken'`).Scan(&rows))
+	assert.Equal(t, 0, rows)
+}
+
+func TestSampleNodes(t *testing.T) {
+
+	pool := &fakeTxPool{statuses: []txPoolStatus{{Pending: 1}}}
+	c := testSampler(t, Config{TxPool: true}, []string{"node0"}, []*client.Client{dialTxPool(t, pool)})
+	c.txPoolPeaks = newTxPoolPeaks(c.nodeNames)
+
+	// Samples at once, then at the collect rate until cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.sampleNodes(ctx, log.Root())
+	}()
+	require.Eventually(t, func() bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return pool.calls >= 3
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, []uint{1}, c.txPoolPeaks.pending)
+
+	// Nothing is sampled unless configured
+	c = testSampler(t, Config{}, nil, nil)
+	assert.False(t, c.SamplesNodes())
+}
@@ -31,4 +31,5 @@ require (
 	github.com/stretchr/testify v1.7.0
 	github.com/vbauerster/mpb v3.4.0+incompatible // indirect
 	github.com/vbauerster/mpb/v7 v7.1.5
+	gopkg.in/yaml.v2 v2.4.0
 )
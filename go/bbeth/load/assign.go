@@ -0,0 +1,105 @@
+package load
+
+import (
+	"fmt"
+
+	"github.com/robinbryce/benchblock/bbeth/client"
+)
+
+// Thread to node assignment strategies
+const (
+	// AssignRoundRobin assigns thread i to node i % nodes
+	AssignRoundRobin = "roundrobin"
+	// AssignWeighted shares the threads between the nodes in proportion to
+	// their endpoint Weight
+	AssignWeighted = "weighted"
+	// AssignNonValidators assigns threads round robin to the nodes which are
+	// not marked as validators. Use to put load only on rpc nodes.
+	AssignNonValidators = "non-validators"
+	// AssignNamed assigns threads round robin to the nodes named in
+	// AssignNodes
+	AssignNamed = "named"
+)
+
+// AssignThreads returns the index, in endpoints, of the node for each of the
+// threads
+func AssignThreads(strategy string, names []string, endpoints []client.Endpoint, threads int) ([]int, error) {
+
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints to assign threads to")
+	}
+
+	var candidates []int
+	switch strategy {
+	case "", AssignRoundRobin, AssignWeighted:
+		for i := range endpoints {
+			candidates = append(candidates, i)
+		}
+	case AssignNonValidators:
+		for i := range endpoints {
+			if !endpoints[i].Validator {
+				candidates = append(candidates, i)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("every endpoint is a validator")
+		}
+	case AssignNamed:
+		byName := map[string]int{}
+		for i := range endpoints {
+			byName[endpoints[i].Name] = i
+		}
+		for _, name := range names {
+			i, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("no endpoint named `%s'", name)
+			}
+			candidates = append(candidates, i)
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("%s assignment requires --assign-nodes", AssignNamed)
+		}
+	default:
+		return nil, fmt.Errorf("unknown assignment strategy `%s'", strategy)
+	}
+
+	if strategy == AssignWeighted {
+		return assignWeighted(endpoints, threads), nil
+	}
+
+	assigned := make([]int, threads)
+	for i := range assigned {
+		assigned[i] = candidates[i%len(candidates)]
+	}
+	return assigned, nil
+}
+
+// assignWeighted uses smooth weighted round robin, so that the threads for
+// each node are interleaved rather than bunched together.
+func assignWeighted(endpoints []client.Endpoint, threads int) []int {
+
+	weights := make([]int, len(endpoints))
+	total := 0
+	for i := range endpoints {
+		weights[i] = endpoints[i].Weight
+		if weights[i] == 0 {
+			weights[i] = 1
+		}
+		total += weights[i]
+	}
+
+	current := make([]int, len(endpoints))
+	assigned := make([]int, threads)
+	for t := range assigned {
+		best := 0
+		for i := range current {
+			current[i] += weights[i]
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		assigned[t] = best
+	}
+	return assigned
+}
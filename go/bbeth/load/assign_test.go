@@ -0,0 +1,48 @@
+package load
+
+import (
+	"testing"
+
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssignThreads(t *testing.T) {
+
+	endpoints := []client.Endpoint{
+		{Name: "v0", Validator: true, Weight: 1},
+		{Name: "v1", Validator: true},
+		{Name: "rpc0", Weight: 2},
+	}
+
+	assigned, err := AssignThreads(AssignRoundRobin, nil, endpoints, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 0}, assigned)
+
+	assigned, err = AssignThreads(AssignWeighted, nil, endpoints, 8)
+	require.NoError(t, err)
+	counts := make([]int, len(endpoints))
+	for _, i := range assigned {
+		counts[i]++
+	}
+	assert.Equal(t, []int{2, 2, 4}, counts)
+
+	// The heavier node's threads are interleaved, not bunched together
+	assigned, err = AssignThreads(AssignWeighted, nil, endpoints, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 0, 1, 2}, assigned)
+
+	assigned, err = AssignThreads(AssignNonValidators, nil, endpoints, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 2, 2}, assigned)
+
+	assigned, err = AssignThreads(AssignNamed, []string{"rpc0", "v1"}, endpoints, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1, 2}, assigned)
+
+	_, err = AssignThreads(AssignNamed, []string{"nope"}, endpoints, 3)
+	assert.Error(t, err)
+	_, err = AssignThreads(AssignNonValidators, nil, endpoints[:2], 3)
+	assert.Error(t, err)
+}
@@ -0,0 +1,358 @@
+package load
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/robinbryce/benchblock/bbeth/root"
+)
+
+// ResolveEndpoints returns the endpoints for the nodes the load is spread
+// across. In order of precedence, they are read from the --endpoints file,
+// derived from --eth by adding the node index to the port, or read from the
+// hosts in --staticnodes.
+func ResolveEndpoints(cfgDir string, rootCfg *root.Config, loadCfg *Config) ([]client.Endpoint, error) {
+	return resolveEndpoints(cfgDir, rootCfg, loadCfg, rootCfg.HostResolver())
+}
+
+func resolveEndpoints(
+	cfgDir string, rootCfg *root.Config, loadCfg *Config, resolver client.HostResolver) ([]client.Endpoint, error) {
+
+	r := endpointResolver{
+		cfgDir: cfgDir, rootCfg: rootCfg, loadCfg: loadCfg, resolver: resolver}
+
+	var endpoints []client.Endpoint
+	var err error
+	switch {
+	case rootCfg.Endpoints != "":
+		endpoints, err = r.fromEndpointsFile()
+	case rootCfg.EthEndpoint != "":
+		endpoints, err = r.fromEthEndpoint()
+	case loadCfg.StaticNodes != "":
+		endpoints, err = r.fromStaticNodes()
+	default:
+		return nil, fmt.Errorf("you must provide one of --endpoints, --eth or --staticnodes")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// PrivateFrom lists a key for each node, in node order. The endpoints
+	// file can set them individually instead.
+	if loadCfg.PrivateFrom != "" {
+		keys := strings.Split(loadCfg.PrivateFrom, ",")
+		for i := range endpoints {
+			if i < len(keys) && endpoints[i].PrivateFrom == "" {
+				endpoints[i].PrivateFrom = strings.TrimSpace(keys[i])
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+type endpointResolver struct {
+	cfgDir   string
+	rootCfg  *root.Config
+	loadCfg  *Config
+	resolver client.HostResolver
+}
+
+// numNodes returns the configured node count, which defaults to the number
+// of threads
+func (r *endpointResolver) numNodes() int {
+	if r.loadCfg.Nodes != 0 {
+		return r.loadCfg.Nodes
+	}
+	return r.loadCfg.Threads
+}
+
+func (r *endpointResolver) fromEndpointsFile() ([]client.Endpoint, error) {
+
+	fileName := r.rootCfg.Endpoints
+	if !filepath.IsAbs(fileName) {
+		fileName = filepath.Join(r.cfgDir, fileName)
+	}
+	listed, err := client.LoadEndpoints(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The urls in the file are used as is, unless hosts are being expanded
+	var endpoints []client.Endpoint
+	for _, ep := range listed {
+		if !r.rootCfg.ExpandHosts {
+			endpoints = append(endpoints, ep)
+			continue
+		}
+		expanded, err := r.expandEndpoint(ep)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, expanded...)
+	}
+
+	// Nodes, if set, selects the first n endpoints
+	if r.loadCfg.Nodes != 0 {
+		if r.loadCfg.Nodes > len(endpoints) {
+			return nil, fmt.Errorf(
+				"to few endpoints in %s. need %d, have %d", r.rootCfg.Endpoints, r.loadCfg.Nodes, len(endpoints))
+		}
+		endpoints = endpoints[:r.loadCfg.Nodes]
+	}
+	return endpoints, nil
+}
+
+// expandEndpoint returns an endpoint for each address of the eth host of ep.
+// If the tessera host is the same, each tessera url gets the same address.
+func (r *endpointResolver) expandEndpoint(ep client.Endpoint) ([]client.Endpoint, error) {
+
+	if client.IsIPCEndpoint(ep.Eth) {
+		return []client.Endpoint{ep}, nil
+	}
+	qu, err := url.Parse(ep.Eth)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := r.resolver.ResolveAll(qu.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 1 {
+		return []client.Endpoint{ep}, nil
+	}
+
+	var tu *url.URL
+	if ep.Tessera != "" {
+		if tu, err = url.Parse(ep.Tessera); err != nil {
+			return nil, err
+		}
+		if tu.Hostname() != qu.Hostname() {
+			tu = nil
+		}
+	}
+
+	endpoints := make([]client.Endpoint, len(hosts))
+	for i, host := range hosts {
+		endpoints[i] = ep
+		endpoints[i].Name = fmt.Sprintf("%s-%d", ep.Name, i)
+		endpoints[i].Eth = withHost(qu, host, qu.Port())
+		endpoints[i].EthHost = resolvedFrom(qu, host)
+		if tu != nil {
+			endpoints[i].Tessera = withHost(tu, host, tu.Port())
+			endpoints[i].TesseraHost = resolvedFrom(tu, host)
+		}
+	}
+	return endpoints, nil
+}
+
+// fromEthEndpoint derives the endpoints from --eth and --tessera. Every node
+// gets a tessera url if --tessera is set, including when there is only one
+// node (or --singlenode). Before endpoints were resolved up front the single
+// node case dropped --tessera, which left private transactions with no
+// transaction manager to store their payload with.
+func (r *endpointResolver) fromEthEndpoint() ([]client.Endpoint, error) {
+
+	nodes := r.numNodes()
+	if r.loadCfg.SingleNode {
+		nodes = 1
+	}
+
+	if err := client.CheckEndpoint(r.rootCfg.EthEndpoint); err != nil {
+		return nil, err
+	}
+
+	// There is no port to derive the other nodes from for an ipc socket, so
+	// every thread shares it.
+	if client.IsIPCEndpoint(r.rootCfg.EthEndpoint) {
+		return []client.Endpoint{{
+			Name: "node-0", Eth: r.rootCfg.EthEndpoint, Tessera: r.loadCfg.TesseraEndpoint}}, nil
+	}
+
+	qu, err := url.Parse(r.rootCfg.EthEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	quHosts, err := r.resolver.ResolveAll(qu.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	baseQuorumPort := r.rootCfg.BasePort
+	if baseQuorumPort == 0 {
+		baseQuorumPort, err = strconv.Atoi(qu.Port())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tu *url.URL
+	var tuHosts []string
+	var baseTesseraPort int
+	if r.loadCfg.TesseraEndpoint != "" {
+		tu, err = url.Parse(r.loadCfg.TesseraEndpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		tuHosts, err = r.resolver.ResolveAll(tu.Hostname())
+		if err != nil {
+			return nil, err
+		}
+
+		baseTesseraPort, err = strconv.Atoi(tu.Port())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A host with many addresses gives a node per address, all on the base
+	// port. Otherwise each node is on the next port up. As for the endpoints
+	// file, it is an error if --nodes asks for more than there are.
+	expanded := len(quHosts) > 1
+	if expanded {
+		if r.loadCfg.Nodes > len(quHosts) {
+			return nil, fmt.Errorf(
+				"to few addresses for %s. need %d, have %d", qu.Hostname(), r.loadCfg.Nodes, len(quHosts))
+		}
+		if r.loadCfg.Nodes == 0 {
+			nodes = len(quHosts)
+		}
+		if r.loadCfg.SingleNode {
+			nodes = 1
+		}
+	}
+	if len(tuHosts) > 1 && len(tuHosts) != len(quHosts) {
+		return nil, fmt.Errorf(
+			"tessera host has %d addresses, the eth host has %d", len(tuHosts), len(quHosts))
+	}
+
+	endpoints := make([]client.Endpoint, nodes)
+	for i := 0; i < nodes; i++ {
+
+		endpoints[i].Name = fmt.Sprintf("node-%d", i)
+		if expanded {
+			endpoints[i].Eth = withHost(qu, quHosts[i], strconv.Itoa(baseQuorumPort))
+			endpoints[i].EthHost = resolvedFrom(qu, quHosts[i])
+		} else {
+			endpoints[i].Eth = withHost(qu, quHosts[0], strconv.Itoa(baseQuorumPort+i))
+			endpoints[i].EthHost = resolvedFrom(qu, quHosts[0])
+		}
+
+		if tu == nil {
+			continue
+		}
+		tuHost := tuHosts[0]
+		if len(tuHosts) > 1 {
+			tuHost = tuHosts[i]
+		}
+		if expanded {
+			endpoints[i].Tessera = withHost(tu, tuHost, strconv.Itoa(baseTesseraPort))
+		} else {
+			endpoints[i].Tessera = withHost(tu, tuHost, strconv.Itoa(baseTesseraPort+i))
+		}
+		endpoints[i].TesseraHost = resolvedFrom(tu, tuHost)
+	}
+	return endpoints, nil
+}
+
+func (r *endpointResolver) fromStaticNodes() ([]client.Endpoint, error) {
+
+	fileName := filepath.Join(r.cfgDir, r.loadCfg.StaticNodes)
+	var staticNodes []string
+	if err := common.LoadJSON(fileName, &staticNodes); err != nil {
+		return nil, fmt.Errorf("loading file `%s': %v", fileName, err)
+	}
+
+	nodes := r.numNodes()
+	if r.loadCfg.SingleNode {
+		nodes = 1
+	}
+
+	quorumPort := r.rootCfg.BasePort
+	if quorumPort == 0 {
+		quorumPort = 8545
+	}
+	tesseraPort := r.loadCfg.BaseTesseraPort
+	if tesseraPort == 0 {
+		tesseraPort = 50000
+	}
+
+	// With ExpandHosts an entry can give more than one node, so keep going
+	// until there are enough
+	var endpoints []client.Endpoint
+	for _, staticNode := range staticNodes {
+
+		if len(endpoints) >= nodes {
+			break
+		}
+
+		// ipc socket paths are used as is, there is no tessera equivalent
+		if client.IsIPCEndpoint(staticNode) {
+			endpoints = append(endpoints, client.Endpoint{Eth: staticNode})
+			continue
+		}
+
+		qu, err := url.Parse(staticNode)
+		if err != nil {
+			return nil, err
+		}
+
+		// enode (and any other non rpc scheme) urls are reached over http
+		quEndpoint := url.URL{Scheme: "http"}
+		switch qu.Scheme {
+		case "http", "https", "ws", "wss":
+			quEndpoint.Scheme = qu.Scheme
+		}
+
+		// Ignore the port in the file. If its an actual static-nodes.json it
+		// will be the p2p port
+		hosts, err := r.resolver.ResolveAll(qu.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			ep := client.Endpoint{
+				Eth: withHost(&quEndpoint, host, strconv.Itoa(quorumPort)), EthHost: resolvedFrom(qu, host)}
+			if r.loadCfg.BaseTesseraPort != 0 {
+				// TODO: Better handling of tessera
+				ep.Tessera = withHost(&url.URL{Scheme: "http"}, host, strconv.Itoa(tesseraPort))
+				ep.TesseraHost = ep.EthHost
+			}
+			endpoints = append(endpoints, ep)
+		}
+	}
+
+	if nodes > len(endpoints) {
+		return nil, fmt.Errorf(
+			"to few nodes in %s. need %d, have %d", r.loadCfg.StaticNodes, nodes, len(endpoints))
+	}
+	endpoints = endpoints[:nodes]
+	for i := range endpoints {
+		endpoints[i].Name = fmt.Sprintf("node-%d", i)
+	}
+	return endpoints, nil
+}
+
+// resolvedFrom returns the host name in u if host is an address resolved from
+// it, or "" if the host is unchanged. The clients present the name, for tls
+// and the Host header, when the url has the address.
+func resolvedFrom(u *url.URL, host string) string {
+	if u.Hostname() == host {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// withHost returns u as a string with its host replaced by host:port
+func withHost(u *url.URL, host, port string) string {
+	v := *u
+	v.Host = net.JoinHostPort(host, port)
+	return v.String()
+}
@@ -0,0 +1,293 @@
+package load
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/robinbryce/benchblock/bbeth/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testResolver resolves the hosts in addrs, expanding those with many
+// addresses
+func testResolver(addrs map[string][]string) client.HostResolver {
+	return client.HostResolver{
+		Resolve: true, Expand: true,
+		LookupIP: func(host string) ([]net.IP, error) {
+			var ips []net.IP
+			for _, addr := range addrs[host] {
+				ips = append(ips, net.ParseIP(addr))
+			}
+			if len(ips) == 0 {
+				return nil, fmt.Errorf("no such host %s", host)
+			}
+			return ips, nil
+		},
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func ethURLs(endpoints []client.Endpoint) (eth, tessera []string) {
+	for _, ep := range endpoints {
+		eth = append(eth, ep.Eth)
+		tessera = append(tessera, ep.Tessera)
+	}
+	return eth, tessera
+}
+
+func TestResolveEndpointsEth(t *testing.T) {
+
+	resolver := testResolver(map[string][]string{
+		"node": {"10.0.0.1"},
+		// The addresses are sorted, so the order dns gives does not matter
+		"nodes": {"10.0.0.3", "10.0.0.2"},
+	})
+
+	for _, tc := range []struct {
+		name       string
+		eth        string
+		tessera    string
+		nodes      int
+		singleNode bool
+		expEth     []string
+		expTessera []string
+		err        bool
+	}{
+		{
+			name: "port offsets", eth: "http://node:8545", tessera: "http://node:9080", nodes: 3,
+			expEth:     []string{"http://10.0.0.1:8545", "http://10.0.0.1:8546", "http://10.0.0.1:8547"},
+			expTessera: []string{"http://10.0.0.1:9080", "http://10.0.0.1:9081", "http://10.0.0.1:9082"},
+		},
+		{
+			name: "no tessera", eth: "ws://node:8546", nodes: 2,
+			expEth: []string{"ws://10.0.0.1:8546", "ws://10.0.0.1:8547"}, expTessera: []string{"", ""},
+		},
+		{
+			// --tessera is kept for a single node, private transactions
+			// need it
+			name: "single node", eth: "http://node:8545", tessera: "http://node:9080", nodes: 3, singleNode: true,
+			expEth: []string{"http://10.0.0.1:8545"}, expTessera: []string{"http://10.0.0.1:9080"},
+		},
+		{
+			name: "one node", eth: "http://node:8545", tessera: "http://node:9080", nodes: 1,
+			expEth: []string{"http://10.0.0.1:8545"}, expTessera: []string{"http://10.0.0.1:9080"},
+		},
+		{
+			name: "expanded hosts", eth: "http://nodes:8545", tessera: "http://nodes:9080",
+			expEth:     []string{"http://10.0.0.2:8545", "http://10.0.0.3:8545"},
+			expTessera: []string{"http://10.0.0.2:9080", "http://10.0.0.3:9080"},
+		},
+		{
+			name: "expanded hosts, fewer nodes", eth: "http://nodes:8545", nodes: 1,
+			expEth: []string{"http://10.0.0.2:8545"}, expTessera: []string{""},
+		},
+		{name: "expanded hosts, too many nodes", eth: "http://nodes:8545", nodes: 3, err: true},
+		{
+			name: "expanded hosts one tessera", eth: "http://nodes:8545", tessera: "http://node:9080",
+			expEth:     []string{"http://10.0.0.2:8545", "http://10.0.0.3:8545"},
+			expTessera: []string{"http://10.0.0.1:9080", "http://10.0.0.1:9080"},
+		},
+		{
+			name: "ipc", eth: "/data/geth.ipc", nodes: 3,
+			expEth: []string{"/data/geth.ipc"}, expTessera: []string{""},
+		},
+		{name: "unknown host", eth: "http://nope:8545", nodes: 1, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+
+			rootCfg := root.NewConfig()
+			rootCfg.EthEndpoint = tc.eth
+			// Take the base port from --eth
+			rootCfg.BasePort = 0
+			loadCfg := NewConfigLoader()
+			loadCfg.Nodes = tc.nodes
+			loadCfg.SingleNode = tc.singleNode
+			loadCfg.TesseraEndpoint = tc.tessera
+
+			endpoints, err := resolveEndpoints("", &rootCfg, &loadCfg, resolver)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			eth, tessera := ethURLs(endpoints)
+			assert.Equal(t, tc.expEth, eth)
+			assert.Equal(t, tc.expTessera, tessera)
+			for i, ep := range endpoints {
+				assert.Equal(t, fmt.Sprintf("node-%d", i), ep.Name)
+			}
+		})
+	}
+}
+
+func TestResolveEndpointsHost(t *testing.T) {
+
+	resolver := testResolver(map[string][]string{
+		"node": {"10.0.0.1"}, "nodes": {"10.0.0.3", "10.0.0.2"}})
+
+	rootCfg := root.NewConfig()
+	rootCfg.EthEndpoint = "https://nodes:8545"
+	rootCfg.BasePort = 0
+	loadCfg := NewConfigLoader()
+	loadCfg.TesseraEndpoint = "https://node:9080"
+
+	// The names the addresses came from are kept for tls and the Host header
+	endpoints, err := resolveEndpoints("", &rootCfg, &loadCfg, resolver)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 2)
+	for _, ep := range endpoints {
+		assert.Equal(t, "nodes", ep.EthHost)
+		assert.Equal(t, "node", ep.TesseraHost)
+	}
+
+	// Nothing to keep if the host was not resolved
+	endpoints, err = resolveEndpoints("", &rootCfg, &loadCfg, client.HostResolver{})
+	require.NoError(t, err)
+	for _, ep := range endpoints {
+		assert.Contains(t, ep.Eth, "https://nodes:")
+		assert.Empty(t, ep.EthHost)
+		assert.Empty(t, ep.TesseraHost)
+	}
+}
+
+func TestResolvedEndpointBasicAuth(t *testing.T) {
+
+	var gotAuth, gotHost string
+	rpcSrv := (&fakeNode{blockNumber: 9}).server(t)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotHost = r.Header.Get("Authorization"), r.Host
+		rpcSrv.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	// The test server certificate is for example.com, which resolves to the
+	// test server
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(
+		&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644))
+	resolver := testResolver(map[string][]string{"example.com": {"127.0.0.1"}})
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	rootCfg := root.NewConfig()
+	rootCfg.EthEndpoint = "https://alice:pw@example.com:" + u.Port()
+	rootCfg.BasePort = 0
+	rootCfg.TransportConfig.TLSCACert = caFile
+	loadCfg := NewConfigLoader()
+	loadCfg.Nodes = 1
+
+	endpoints, err := resolveEndpoints("", &rootCfg, &loadCfg, resolver)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	ep := endpoints[0]
+	// The address replaces the host, the credentials are kept
+	assert.Equal(t, "https://alice:pw@127.0.0.1:"+u.Port(), ep.Eth)
+
+	c, err := client.NewClient(ep.Eth, "", time.Second, endpointClientOptions(&rootCfg, &loadCfg, ep)...)
+	require.NoError(t, err)
+	defer c.Close()
+	n, err := c.BlockNumber(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(9), n)
+	assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("alice:pw")), gotAuth)
+	assert.Equal(t, "example.com", gotHost)
+}
+
+func TestResolveEndpointsFile(t *testing.T) {
+
+	dir := t.TempDir()
+	writeFile(t, dir, "endpoints.yaml", `
+- name: a
+  eth: http://nodes:8545
+  tessera: http://nodes:9080
+- name: b
+  eth: http://node:8545
+  private-from: keyB
+`)
+
+	rootCfg := root.NewConfig()
+	rootCfg.Endpoints = "endpoints.yaml"
+	loadCfg := NewConfigLoader()
+	loadCfg.PrivateFrom = "keyA0,keyA1,keyOther"
+	resolver := testResolver(map[string][]string{"node": {"10.0.0.1"}, "nodes": {"10.0.0.2", "10.0.0.3"}})
+
+	// Without ExpandHosts the urls are used as is
+	endpoints, err := resolveEndpoints(dir, &rootCfg, &loadCfg, resolver)
+	require.NoError(t, err)
+	eth, _ := ethURLs(endpoints)
+	assert.Equal(t, []string{"http://nodes:8545", "http://node:8545"}, eth)
+
+	rootCfg.ExpandHosts = true
+	endpoints, err = resolveEndpoints(dir, &rootCfg, &loadCfg, resolver)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 3)
+	eth, tessera := ethURLs(endpoints)
+	assert.Equal(t, []string{"http://10.0.0.2:8545", "http://10.0.0.3:8545", "http://node:8545"}, eth)
+	assert.Equal(t, []string{"http://10.0.0.2:9080", "http://10.0.0.3:9080", ""}, tessera)
+	assert.Equal(t, "a-0", endpoints[0].Name)
+	assert.Equal(t, "a-1", endpoints[1].Name)
+	// --privatefrom fills in keys the file does not set, in node order
+	assert.Equal(t, "keyA0", endpoints[0].PrivateFrom)
+	assert.Equal(t, "keyA1", endpoints[1].PrivateFrom)
+	assert.Equal(t, "keyB", endpoints[2].PrivateFrom)
+
+	loadCfg.Nodes = 2
+	endpoints, err = resolveEndpoints(dir, &rootCfg, &loadCfg, resolver)
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 2)
+
+	loadCfg.Nodes = 4
+	_, err = resolveEndpoints(dir, &rootCfg, &loadCfg, resolver)
+	assert.Error(t, err)
+}
+
+func TestResolveEndpointsStaticNodes(t *testing.T) {
+
+	dir := t.TempDir()
+	writeFile(t, dir, "static-nodes.json", `[
+		"enode://abc@node:30303",
+		"wss://nodes:443",
+		"/data/geth.ipc"
+	]`)
+
+	rootCfg := root.NewConfig()
+	rootCfg.BasePort = 8545
+	loadCfg := NewConfigLoader()
+	loadCfg.StaticNodes = "static-nodes.json"
+	loadCfg.BaseTesseraPort = 9080
+	loadCfg.Nodes = 4
+	resolver := testResolver(map[string][]string{"node": {"10.0.0.1"}, "nodes": {"10.0.0.2", "10.0.0.3"}})
+
+	endpoints, err := resolveEndpoints(dir, &rootCfg, &loadCfg, resolver)
+	require.NoError(t, err)
+	eth, tessera := ethURLs(endpoints)
+	assert.Equal(t, []string{
+		"http://10.0.0.1:8545", "wss://10.0.0.2:8545", "wss://10.0.0.3:8545", "/data/geth.ipc"}, eth)
+	assert.Equal(t, []string{
+		"http://10.0.0.1:9080", "http://10.0.0.2:9080", "http://10.0.0.3:9080", ""}, tessera)
+
+	loadCfg.Nodes = 5
+	_, err = resolveEndpoints(dir, &rootCfg, &loadCfg, resolver)
+	assert.Error(t, err)
+
+	loadCfg.Nodes = 4
+	loadCfg.SingleNode = true
+	endpoints, err = resolveEndpoints(dir, &rootCfg, &loadCfg, resolver)
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+}
@@ -0,0 +1,102 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/robinbryce/benchblock/bbeth/client"
+)
+
+// transferGas is the gas used by a plain value transfer
+const transferGas = 21000
+
+// checkFunding returns an error if the thread accounts will need funds but
+// there is no DeployKey to fund them from. It is checked before anything is
+// deployed.
+func checkFunding(cfg *Config) error {
+	if cfg.GasPrice != 0 && cfg.DeployKey == "" {
+		return fmt.Errorf(
+			"a gas price of %d wei needs --deploy-key to fund the thread accounts", cfg.GasPrice)
+	}
+	return nil
+}
+
+// threadAccountFunds returns the balance each thread account needs to pay
+// for its share of the transactions at the configured gas limit and price
+func threadAccountFunds(cfg *Config, gasPrice uint64) *big.Int {
+	perAccount := cfg.NumTransactions / (cfg.Threads * cfg.ThreadAccounts)
+	funds := new(big.Int).SetUint64(cfg.GasLimit)
+	funds.Mul(funds, new(big.Int).SetUint64(gasPrice))
+	return funds.Mul(funds, big.NewInt(int64(perAccount)))
+}
+
+// fundingCost is the total cost to the DeployKey account of funding every
+// thread account, including the gas for the transfers
+func fundingCost(cfg *Config, gasPrice uint64) *big.Int {
+	perAccount := new(big.Int).SetUint64(transferGas * gasPrice)
+	perAccount.Add(perAccount, threadAccountFunds(cfg, gasPrice))
+	return perAccount.Mul(perAccount, big.NewInt(int64(cfg.Threads*cfg.ThreadAccounts)))
+}
+
+// fundAccounts transfers enough, from the DeployKey account, to each thread
+// account to pay for its transactions. The thread accounts are generated
+// fresh for each run, so nothing else funds them. Nothing is transferred if
+// the gas price is 0.
+func (a *Loader) fundAccounts(ctx context.Context) error {
+
+	if a.loadCfg.GasPrice == 0 {
+		return nil
+	}
+	if err := checkFunding(a.loadCfg); err != nil {
+		return err
+	}
+	key, err := crypto.HexToECDSA(a.loadCfg.DeployKey)
+	if err != nil {
+		return err
+	}
+	auth := client.NewKeyedTransactor(key, a.chainID)
+	funds := threadAccountFunds(a.loadCfg, a.loadCfg.GasPrice)
+	price := new(big.Int).SetUint64(a.loadCfg.GasPrice)
+
+	rctx, cancel := context.WithTimeout(ctx, a.rootCfg.ClientTimeout)
+	nonce, err := a.ethC[0].PendingNonceAt(rctx, auth.From)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	var txs []*types.Transaction
+	for i := range a.accounts {
+		for _, wallet := range a.accounts[i].Wallets {
+			tx, err := auth.Signer(types.HomesteadSigner{}, auth.From,
+				types.NewTransaction(nonce, wallet, funds, transferGas, price, nil))
+			if err != nil {
+				return err
+			}
+			rctx, cancel := context.WithTimeout(ctx, a.rootCfg.ClientTimeout)
+			err = a.ethC[0].SendTransaction(rctx, tx, bind.PrivateTxArgs{})
+			cancel()
+			if err != nil {
+				return fmt.Errorf("funding thread account %s: %w", wallet.Hex(), err)
+			}
+			nonce++
+			txs = append(txs, tx)
+		}
+	}
+
+	for _, tx := range txs {
+		receipt, err := client.WaitReceipt(a.ethC[0].Client, tx, a.rootCfg.Retries, a.loadCfg.ExpectedLatency)
+		if err != nil {
+			return fmt.Errorf("funding thread account %s: %w", tx.To().Hex(), err)
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return fmt.Errorf("funding thread account %s failed", tx.To().Hex())
+		}
+	}
+	a.log.Info("funded thread accounts", "accounts", len(txs), "wei", funds)
+	return nil
+}
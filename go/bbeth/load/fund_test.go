@@ -0,0 +1,73 @@
+package load
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckFunding(t *testing.T) {
+
+	cfg := NewConfigLoader()
+	assert.NoError(t, checkFunding(&cfg))
+
+	cfg.GasPrice = 1
+	assert.Error(t, checkFunding(&cfg))
+
+	cfg.DeployKey = "01"
+	assert.NoError(t, checkFunding(&cfg))
+}
+
+func TestThreadAccountFunds(t *testing.T) {
+
+	cfg := NewConfigLoader()
+	cfg.Threads, cfg.ThreadAccounts, cfg.NumTransactions = 2, 3, 12
+	cfg.GasLimit = 60000
+
+	// Two transactions per account
+	assert.Equal(t, big.NewInt(2*60000*10), threadAccountFunds(&cfg, 10))
+	assert.Equal(t, big.NewInt(6*(21000*10+2*60000*10)), fundingCost(&cfg, 10))
+	assert.Equal(t, 0, fundingCost(&cfg, 0).Sign())
+}
+
+func TestFundAccounts(t *testing.T) {
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	lo := testLoader(t, 2, 2, 8)
+	lo.rootCfg.Retries = 1
+	lo.loadCfg.GasLimit = 60000
+	node := &fakeNode{mine: true, nonce: 7}
+	lo.ethC = []*client.Client{node.dial(t)}
+
+	// Nothing to fund without a gas price
+	require.NoError(t, lo.fundAccounts(context.Background()))
+	assert.Empty(t, node.sentTxs)
+
+	lo.loadCfg.GasPrice = 10
+	assert.Error(t, lo.fundAccounts(context.Background()), "funding needs the deploy key")
+
+	lo.loadCfg.DeployKey = hex.EncodeToString(crypto.FromECDSA(key))
+	require.NoError(t, lo.fundAccounts(context.Background()))
+
+	signer := types.NewEIP155Signer(lo.chainID)
+	require.Len(t, node.sentTxs, 4)
+	for i, tx := range node.sentTxs {
+		from, err := types.Sender(signer, tx)
+		require.NoError(t, err)
+		assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), from)
+		assert.Equal(t, uint64(7+i), tx.Nonce())
+		assert.Equal(t, lo.accounts[i/2].Wallets[i%2], *tx.To())
+		// Two transactions each, at the thread gas limit and price
+		assert.Equal(t, big.NewInt(2*60000*10), tx.Value())
+		assert.Equal(t, big.NewInt(10), tx.GasPrice())
+	}
+}
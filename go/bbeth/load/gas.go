@@ -0,0 +1,132 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// estimateGas calls eth_estimateGas for msg and scales the result by the
+// configured GasMultiplier. The estimate is exact for the state the node
+// evaluates it against, the multiplier gives head room for state that changes
+// under load.
+func (a *Loader) estimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, a.rootCfg.ClientTimeout)
+	defer cancel()
+
+	gas, err := a.ethC[0].EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, err
+	}
+	return scaleGas(gas, a.loadCfg.GasMultiplier), nil
+}
+
+// scaleGas applies the gas multiplier to an estimate. Multipliers less than 1
+// are treated as 1.
+func scaleGas(gas uint64, multiplier float64) uint64 {
+	if multiplier < 1.0 {
+		multiplier = 1.0
+	}
+	return uint64(math.Ceil(float64(gas) * multiplier))
+}
+
+// estimateDeployGas estimates the gas limit for deploying the contract code
+func (a *Loader) estimateDeployGas(ctx context.Context, from common.Address, code []byte) (uint64, error) {
+
+	gas, err := a.estimateGas(ctx, ethereum.CallMsg{From: from, Data: code})
+	if err != nil {
+		return 0, fmt.Errorf("eth_estimateGas for deploy: %w", err)
+	}
+	return gas, nil
+}
+
+// estimateMethodGas estimates the gas limit for calling method on the
+// deployed contract. The first account of the first thread is used as the
+// sender. No gas price is set on the call so that unfunded accounts can be
+// estimated for.
+func (a *Loader) estimateMethodGas(ctx context.Context, method string, params ...interface{}) (uint64, error) {
+
+	input, err := a.abi.Pack(method, params...)
+	if err != nil {
+		return 0, err
+	}
+	gas, err := a.estimateGas(ctx, ethereum.CallMsg{
+		From: a.accounts[0].Wallets[0], To: &a.address, Data: input})
+	if err != nil {
+		return 0, fmt.Errorf("eth_estimateGas for %s: %w", method, err)
+	}
+	return gas, nil
+}
+
+// GasReport accumulates the gas used, from transaction receipts, for each
+// kind of transaction issued. It is safe for concurrent use.
+type GasReport struct {
+	mu    sync.Mutex
+	kinds map[string]*gasUsage
+}
+
+type gasUsage struct {
+	limit uint64
+	count uint64
+	total uint64
+	min   uint64
+	max   uint64
+}
+
+func NewGasReport() *GasReport {
+	return &GasReport{kinds: map[string]*gasUsage{}}
+}
+
+// Add records the gas used by the receipt against the kind of transaction
+func (g *GasReport) Add(kind string, tx *types.Transaction, r *types.Receipt) {
+	if r == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	u, ok := g.kinds[kind]
+	if !ok {
+		u = &gasUsage{min: r.GasUsed}
+		g.kinds[kind] = u
+	}
+	u.limit = tx.Gas()
+	u.count++
+	u.total += r.GasUsed
+	if r.GasUsed < u.min {
+		u.min = r.GasUsed
+	}
+	if r.GasUsed > u.max {
+		u.max = r.GasUsed
+	}
+}
+
+// Print writes one summary line per kind of transaction. Nothing is printed if
+// no receipts were recorded.
+func (g *GasReport) Print() {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	kinds := make([]string, 0, len(g.kinds))
+	for kind := range g.kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		u := g.kinds[kind]
+		fmt.Printf(
+			"gas %s: receipts %d, used min %d, mean %d, max %d, limit %d (%.1f%% of limit at max)\n",
+			kind, u.count, u.min, u.total/u.count, u.max, u.limit,
+			100.0*float64(u.max)/float64(u.limit))
+	}
+}
@@ -0,0 +1,36 @@
+package load
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGasReport(t *testing.T) {
+
+	tx := types.NewTransaction(0, common.Address{}, new(big.Int), 60000, new(big.Int), nil)
+
+	g := NewGasReport()
+	g.Add("add", tx, &types.Receipt{GasUsed: 26000})
+	g.Add("add", tx, &types.Receipt{GasUsed: 41000})
+	g.Add("add", tx, &types.Receipt{GasUsed: 29000})
+	// No receipt, nothing to record
+	g.Add("add", tx, nil)
+	g.Add("deploy", tx, &types.Receipt{GasUsed: 50000})
+
+	require.Contains(t, g.kinds, "add")
+	u := g.kinds["add"]
+	assert.Equal(t, uint64(3), u.count)
+	assert.Equal(t, uint64(96000), u.total)
+	assert.Equal(t, uint64(26000), u.min)
+	assert.Equal(t, uint64(41000), u.max)
+	assert.Equal(t, uint64(60000), u.limit)
+
+	require.Contains(t, g.kinds, "deploy")
+	assert.Equal(t, uint64(50000), g.kinds["deploy"].min)
+	assert.Equal(t, uint64(50000), g.kinds["deploy"].max)
+}
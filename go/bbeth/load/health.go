@@ -0,0 +1,300 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/robinbryce/benchblock/bbeth/client"
+)
+
+// NodeHealth is the result of the most recent probe of a node
+type NodeHealth struct {
+	Healthy bool
+	// Reachable is set if the node answered eth_blockNumber
+	Reachable   bool
+	Reason      string
+	BlockNumber uint64
+	Peers       uint64
+	Syncing     bool
+	Checked     time.Time
+}
+
+// FailoverEvent records a thread being moved from an unhealthy node
+type FailoverEvent struct {
+	Time   time.Time
+	Thread int
+	From   string
+	To     string
+	Reason string
+}
+
+// healthMonitor periodically probes every node with eth_blockNumber,
+// net_peerCount and eth_syncing. It has its own connection to each node so
+// that probes are not queued behind the load.
+type healthMonitor struct {
+	endpoints []client.Endpoint
+	probes    []*client.Client
+	interval  time.Duration
+	timeout   time.Duration
+	// A node with no peers is only unhealthy if there are other nodes it
+	// should be connected to
+	requirePeers bool
+
+	log log.Logger
+
+	// dial connects the clients for threads failing over to a node. It
+	// includes tessera, unlike the probes.
+	dial func(ep client.Endpoint) (*client.Client, error)
+
+	mu              sync.RWMutex
+	status          []NodeHealth
+	failovers       []FailoverEvent
+	failoverClients map[int]*client.Client
+}
+
+func newHealthMonitor(a *Loader) (*healthMonitor, error) {
+
+	h := &healthMonitor{
+		endpoints:    a.endpoints,
+		probes:       make([]*client.Client, len(a.endpoints)),
+		interval:     a.loadCfg.HealthInterval,
+		timeout:      a.rootCfg.ClientTimeout,
+		requirePeers: len(a.endpoints) > 1,
+		status:       make([]NodeHealth, len(a.endpoints)),
+		log:          a.log.New("component", "health"),
+		dial: func(ep client.Endpoint) (*client.Client, error) {
+			return client.NewClient(ep.Eth, ep.Tessera, a.rootCfg.ClientTimeout, a.clientOptions(ep)...)
+		},
+		failoverClients: map[int]*client.Client{},
+	}
+
+	var err error
+	for i, ep := range a.endpoints {
+		h.probes[i], err = client.NewClient(ep.Eth, "", a.rootCfg.ClientTimeout, a.clientOptions(ep)...)
+		if err != nil {
+			return nil, fmt.Errorf("connecting health probe to %s (%s): %w", ep.Name, ep.Eth, err)
+		}
+		// Assume healthy until the first probe says otherwise
+		h.status[i] = NodeHealth{Healthy: true}
+	}
+	return h, nil
+}
+
+// Run probes all nodes every interval until ctx is done
+func (h *healthMonitor) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.probeAll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *healthMonitor) probeAll() {
+
+	var wg sync.WaitGroup
+	results := make([]NodeHealth, len(h.probes))
+	for i := range h.probes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = h.probe(i)
+		}(i)
+	}
+	wg.Wait()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, nh := range results {
+		if nh.Healthy != h.status[i].Healthy {
+			if nh.Healthy {
+				h.log.Info("node is healthy again", "node", h.endpoints[i].Name)
+			} else {
+				h.log.Warn("node is unhealthy", "node", h.endpoints[i].Name, "reason", nh.Reason)
+			}
+		}
+		h.status[i] = nh
+	}
+}
+
+func (h *healthMonitor) probe(i int) NodeHealth {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	return probeNode(ctx, h.probes[i], h.requirePeers)
+}
+
+// probeNode checks the node is responsive, has peers (if requirePeers is set)
+// and is not syncing.
+func probeNode(ctx context.Context, ethC *client.Client, requirePeers bool) NodeHealth {
+
+	nh := NodeHealth{Checked: time.Now()}
+
+	var err error
+	if nh.BlockNumber, err = ethC.BlockNumber(ctx); err != nil {
+		nh.Reason = fmt.Sprintf("eth_blockNumber: %v", err)
+		return nh
+	}
+	nh.Reachable = true
+
+	var peers hexutil.Uint64
+	if err = ethC.RPC.CallContext(ctx, &peers, "net_peerCount"); err != nil {
+		nh.Reason = fmt.Sprintf("net_peerCount: %v", err)
+		return nh
+	}
+	nh.Peers = uint64(peers)
+
+	progress, err := ethC.SyncProgress(ctx)
+	if err != nil {
+		nh.Reason = fmt.Sprintf("eth_syncing: %v", err)
+		return nh
+	}
+	nh.Syncing = progress != nil
+
+	switch {
+	case nh.Syncing:
+		nh.Reason = fmt.Sprintf("syncing, at %d of %d", progress.CurrentBlock, progress.HighestBlock)
+	case requirePeers && nh.Peers == 0:
+		nh.Reason = "no peers"
+	default:
+		nh.Healthy = true
+	}
+	return nh
+}
+
+// Status returns the most recent health of node i
+func (h *healthMonitor) Status(i int) NodeHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.status[i]
+}
+
+// pickHealthy returns a healthy node from candidates, or -1 if there are
+// none. Threads are spread over the healthy nodes by their index.
+func (h *healthMonitor) pickHealthy(candidates []int, thread int) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var healthy []int
+	for _, i := range candidates {
+		if h.status[i].Healthy {
+			healthy = append(healthy, i)
+		}
+	}
+	if len(healthy) == 0 {
+		return -1
+	}
+	return healthy[thread%len(healthy)]
+}
+
+// distinctNodes returns the distinct nodes the threads are assigned to.
+// Failover stays within the original assignment so that, for example, load is
+// not moved on to validators.
+func distinctNodes(threadNode []int) []int {
+	seen := map[int]bool{}
+	var candidates []int
+	for _, i := range threadNode {
+		if !seen[i] {
+			seen[i] = true
+			candidates = append(candidates, i)
+		}
+	}
+	return candidates
+}
+
+// failoverClient returns the client threads failing over to node i share. It
+// is created on first use and kept for the rest of the run, so repeated
+// failovers between nodes reuse connections rather than dialing new ones.
+// The clients threads move off are not closed: the receipt poller may still
+// be waiting on transactions sent through them, and private receipts are
+// only available from the node the transaction was sent to.
+func (h *healthMonitor) failoverClient(i int) (*client.Client, error) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if ethC := h.failoverClients[i]; ethC != nil {
+		return ethC, nil
+	}
+	ethC, err := h.dial(h.endpoints[i])
+	if err != nil {
+		return nil, err
+	}
+	h.failoverClients[i] = ethC
+	return ethC, nil
+}
+
+// failover moves thread ias to a healthy node if its current node is
+// unhealthy. Only the thread itself calls this, so its connection and binding
+// can be replaced without locking.
+func (a *Loader) failover(ias int) {
+
+	if a.health == nil || !a.loadCfg.Failover {
+		return
+	}
+
+	from := a.threadNode[ias]
+	status := a.health.Status(from)
+	if status.Healthy {
+		return
+	}
+
+	to := a.health.pickHealthy(a.failoverNodes, ias)
+	if to == -1 || to == from {
+		return
+	}
+
+	ep := a.endpoints[to]
+	ethC, err := a.health.failoverClient(to)
+	if err != nil {
+		a.log.Error("failover failed", "thread", ias, "to", ep.Name, "err", err)
+		return
+	}
+
+	a.ethC[ias] = ethC
+	a.ethCUrl[ias] = ep.Eth
+	// The threads own key, if it has one, is only held by the tessera of its
+	// original node
+	a.contracts[ias] = a.newContractBinding(ethC, to, ep.PrivateFrom)
+	a.threadNode[ias] = to
+
+	ev := FailoverEvent{
+		Time: time.Now(), Thread: ias, From: a.endpoints[from].Name, To: ep.Name, Reason: status.Reason}
+	a.log.Warn("failover", "thread", ias, "from", ev.From, "to", ev.To, "reason", ev.Reason)
+
+	a.health.mu.Lock()
+	a.health.failovers = append(a.health.failovers, ev)
+	a.health.mu.Unlock()
+
+	if a.collector != nil {
+		if err := a.collector.RecordFailover(ev.Time, ev.Thread, ev.From, ev.To, ev.Reason); err != nil {
+			a.log.Error("recording failover", "err", err)
+		}
+	}
+}
+
+// printFailovers logs a summary of the failover events for the run
+func (a *Loader) printFailovers() {
+	if a.health == nil {
+		return
+	}
+	a.health.mu.RLock()
+	defer a.health.mu.RUnlock()
+	if len(a.health.failovers) == 0 {
+		return
+	}
+	a.log.Info("failovers", "count", len(a.health.failovers))
+	for _, ev := range a.health.failovers {
+		a.log.Info("failover", "time", ev.Time.Format(time.RFC3339), "thread", ev.Thread,
+			"from", ev.From, "to", ev.To, "reason", ev.Reason)
+	}
+}
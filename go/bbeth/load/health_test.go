@@ -0,0 +1,188 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeNode(t *testing.T) {
+
+	for _, tc := range []struct {
+		name         string
+		node         *fakeNode
+		requirePeers bool
+		healthy      bool
+		reachable    bool
+		reason       string
+	}{
+		{name: "healthy", node: &fakeNode{blockNumber: 10, peers: 2}, requirePeers: true, healthy: true, reachable: true},
+		{name: "down", node: &fakeNode{down: true}, requirePeers: true},
+		{name: "no peers", node: &fakeNode{blockNumber: 10}, requirePeers: true, reachable: true, reason: "no peers"},
+		{name: "no peers needed", node: &fakeNode{blockNumber: 10}, healthy: true, reachable: true},
+		{
+			name: "syncing", node: &fakeNode{blockNumber: 10, peers: 2, syncing: 20}, requirePeers: true,
+			reachable: true, reason: "syncing, at 10 of 20",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nh := probeNode(context.Background(), tc.node.dial(t), tc.requirePeers)
+			assert.Equal(t, tc.healthy, nh.Healthy)
+			assert.Equal(t, tc.reachable, nh.Reachable)
+			if tc.reason != "" {
+				assert.Equal(t, tc.reason, nh.Reason)
+			}
+			if !tc.healthy {
+				assert.NotEmpty(t, nh.Reason)
+			}
+		})
+	}
+}
+
+// testHealthMonitor monitors the fake nodes, which start healthy
+func testHealthMonitor(t *testing.T, nodes []*fakeNode) *healthMonitor {
+
+	h := &healthMonitor{
+		timeout:         time.Second,
+		requirePeers:    true,
+		log:             log.Root(),
+		failoverClients: map[int]*client.Client{},
+	}
+	byEth := map[string]*fakeNode{}
+	for i, n := range nodes {
+		n.set(func(n *fakeNode) { n.blockNumber, n.peers = 1, 1 })
+		ep := client.Endpoint{Name: fmt.Sprintf("node-%d", i), Eth: fmt.Sprintf("inproc-%d", i)}
+		byEth[ep.Eth] = n
+		h.endpoints = append(h.endpoints, ep)
+		h.probes = append(h.probes, n.dial(t))
+		h.status = append(h.status, NodeHealth{Healthy: true})
+	}
+	h.dial = func(ep client.Endpoint) (*client.Client, error) {
+		return byEth[ep.Eth].dial(t), nil
+	}
+	return h
+}
+
+func TestHealthTransitions(t *testing.T) {
+
+	nodes := []*fakeNode{{}, {}}
+	h := testHealthMonitor(t, nodes)
+
+	steps := []struct {
+		change  func(n *fakeNode)
+		healthy bool
+	}{
+		{change: func(n *fakeNode) {}, healthy: true},
+		{change: func(n *fakeNode) { n.down = true }, healthy: false},
+		{change: func(n *fakeNode) { n.down = false; n.syncing = 100 }, healthy: false},
+		{change: func(n *fakeNode) { n.syncing = 0; n.peers = 0 }, healthy: false},
+		{change: func(n *fakeNode) { n.peers = 3 }, healthy: true},
+	}
+	for i, step := range steps {
+		nodes[0].set(step.change)
+		h.probeAll()
+		assert.Equal(t, step.healthy, h.Status(0).Healthy, "step %d", i)
+		// The other node is unaffected
+		assert.True(t, h.Status(1).Healthy, "step %d", i)
+	}
+}
+
+func TestPickHealthy(t *testing.T) {
+
+	h := &healthMonitor{status: []NodeHealth{
+		{Healthy: true}, {Healthy: false}, {Healthy: true}, {Healthy: true}}}
+
+	for _, tc := range []struct {
+		name       string
+		candidates []int
+		thread     int
+		want       int
+	}{
+		{name: "first healthy", candidates: []int{0, 1, 2}, thread: 0, want: 0},
+		{name: "spread by thread", candidates: []int{0, 1, 2}, thread: 1, want: 2},
+		{name: "wraps", candidates: []int{0, 1, 2}, thread: 2, want: 0},
+		{name: "candidates only", candidates: []int{1, 3}, thread: 0, want: 3},
+		{name: "none healthy", candidates: []int{1}, thread: 0, want: -1},
+		{name: "no candidates", candidates: nil, thread: 0, want: -1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, h.pickHealthy(tc.candidates, tc.thread))
+		})
+	}
+}
+
+func TestDistinctNodes(t *testing.T) {
+	assert.Equal(t, []int{2, 0, 1}, distinctNodes([]int{2, 0, 2, 1, 0}))
+}
+
+func TestFailover(t *testing.T) {
+
+	nodes := []*fakeNode{{}, {}, {}}
+	lo := testLoader(t, 4, 1, 4)
+	lo.loadCfg.Failover = true
+	lo.health = testHealthMonitor(t, nodes)
+	lo.endpoints = lo.health.endpoints
+	// Node 2 has no threads, so it is not a failover candidate
+	lo.threadNode = []int{0, 1, 0, 1}
+	lo.failoverNodes = distinctNodes(lo.threadNode)
+	for i, node := range lo.threadNode {
+		lo.ethC = append(lo.ethC, lo.health.probes[node])
+		lo.ethCUrl = append(lo.ethCUrl, lo.endpoints[node].Eth)
+		lo.contracts = append(lo.contracts, lo.newContractBinding(lo.ethC[i], node, lo.endpoints[node].PrivateFrom))
+	}
+	original := append([]*client.Client(nil), lo.ethC...)
+
+	// Healthy nodes, nothing moves
+	for ias := range lo.threadNode {
+		lo.failover(ias)
+	}
+	assert.Equal(t, []int{0, 1, 0, 1}, lo.threadNode)
+
+	nodes[0].set(func(n *fakeNode) { n.down = true })
+	lo.health.probeAll()
+	for ias := range lo.threadNode {
+		lo.failover(ias)
+	}
+	assert.Equal(t, []int{1, 1, 1, 1}, lo.threadNode)
+	assert.Equal(t, []string{"inproc-1", "inproc-1", "inproc-1", "inproc-1"}, lo.ethCUrl)
+
+	// Threads failing over to a node share one client for it, and threads
+	// already there keep theirs
+	require.NotNil(t, lo.health.failoverClients[1])
+	assert.Same(t, lo.health.failoverClients[1], lo.ethC[0])
+	assert.Same(t, lo.ethC[0], lo.ethC[2])
+	assert.Same(t, original[1], lo.ethC[1])
+	assert.Len(t, lo.health.failoverClients, 1)
+
+	require.Len(t, lo.health.failovers, 2)
+	for i, ev := range lo.health.failovers {
+		assert.Equal(t, 2*i, ev.Thread)
+		assert.Equal(t, "node-0", ev.From)
+		assert.Equal(t, "node-1", ev.To)
+		assert.Contains(t, ev.Reason, "eth_blockNumber")
+	}
+
+	// Back again, when node 1 fails and node 0 recovers
+	nodes[0].set(func(n *fakeNode) { n.down = false })
+	nodes[1].set(func(n *fakeNode) { n.down = true })
+	lo.health.probeAll()
+	for ias := range lo.threadNode {
+		lo.failover(ias)
+	}
+	assert.Equal(t, []int{0, 0, 0, 0}, lo.threadNode)
+	assert.Len(t, lo.health.failoverClients, 2)
+	assert.Len(t, lo.health.failovers, 6)
+
+	// Nowhere healthy to go
+	nodes[0].set(func(n *fakeNode) { n.down = true })
+	lo.health.probeAll()
+	lo.failover(0)
+	assert.Equal(t, 0, lo.threadNode[0])
+	assert.Len(t, lo.health.failovers, 6)
+}
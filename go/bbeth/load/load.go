@@ -5,10 +5,7 @@ import (
 	"crypto/ecdsa"
 	"fmt"
 	"math/big"
-	"net"
-	"net/url"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +16,8 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/private/engine"
 	"github.com/robinbryce/benchblock/bbeth/client"
 	"github.com/robinbryce/benchblock/bbeth/collect"
 	"github.com/robinbryce/benchblock/bbeth/root"
@@ -61,6 +60,23 @@ func WithCollector(c *collect.Collector) LoaderOption {
 	}
 }
 
+// WithLogger sets the logger, the default is the go-ethereum root logger.
+// Each thread adds its index and node to the context.
+func WithLogger(l log.Logger) LoaderOption {
+	return func(lo *Loader) {
+		lo.log = l
+	}
+}
+
+// WithMetrics records live metrics for issued and failed transactions, and
+// the number in flight
+func WithMetrics(m *client.Metrics) LoaderOption {
+	return func(lo *Loader) {
+		lo.metrics = m
+		m.TrackInFlight()
+	}
+}
+
 const (
 	ConfigName = "load"
 )
@@ -86,8 +102,17 @@ type Config struct {
 	// resourced.
 	SingleNode bool `mapstructure:"singlenode"`
 
-	// If true, confirm every transaction in a batch before doing the next batch.
-	CheckReceipts bool `mapstructure:"check-receipts"`
+	// Assign selects the strategy for assigning threads to nodes. One of
+	// roundrobin, weighted, non-validators or named. AssignNodes lists the
+	// node names for named.
+	Assign      string   `mapstructure:"assign"`
+	AssignNodes []string `mapstructure:"assign-nodes"`
+
+	// If true, check the receipt of every transaction. The receipts are
+	// checked by a pool of ReceiptPollers, concurrently with sending. 0
+	// means one poller per thread.
+	CheckReceipts  bool `mapstructure:"check-receipts"`
+	ReceiptPollers int  `mapstructure:"receipt-pollers"`
 
 	TesseraEndpoint string `mapstructure:"tesera"`
 	// If staticnodes is provided, Nodes hosts are read from the file. Its
@@ -97,11 +122,63 @@ type Config struct {
 	StaticNodes     string `mapstructure:"staticnodes"`
 	BaseTesseraPort int    `mapstructure:"basetesseraport"`
 
+	// Credentials for the tessera endpoints, see root.Config AuthToken
+	TesseraAuthToken string `mapstructure:"tessera-auth-token"`
+	TesseraJWTSecret string `mapstructure:"tessera-jwt-secret"`
+
 	ExpectedLatency time.Duration `mapstructure:"expected_latency"`
 
+	// EstimateGas replaces the fixed GasLimit and DeployGasLimit with values
+	// from eth_estimateGas, made once for each kind of transaction at startup
+	// and scaled by GasMultiplier.
+	EstimateGas   bool    `mapstructure:"estimate-gas"`
+	GasMultiplier float64 `mapstructure:"gas-multiplier"`
+
 	DeployGasLimit uint64 `mapstructure:"deploy-gaslimit"`
 	DeployKey      string `mapstructure:"deploy-key"` // needs to have funds even for quorum, used to deploy contract
 	RunOne         bool   `mapstructure:"run_one"`
+
+	// Presign builds and signs all transactions before the timed phase, which
+	// then only calls eth_sendRawTransaction. This takes the cost of abi
+	// encoding and signing out of the achievable send rate.
+	Presign bool `mapstructure:"presign"`
+
+	// BatchSize > 1 submits transactions in json-rpc batch requests of this
+	// size. It implies Presign.
+	BatchSize int `mapstructure:"batch-size"`
+
+	// SaveContract names a file to record the address of the deployed
+	// contract in. Pass the file to --contract-address to re-use the deployment.
+	SaveContract string `mapstructure:"save-contract"`
+
+	// PrivateFrom is a ',' separated list of tessera keys, one per node in
+	// node order, that private transactions are sent from.
+	// ThreadPrivateFrom is the same for each thread, in thread order, and
+	// takes precedence. A threads key must be held by the tessera of the node
+	// it is assigned to. PrivacyFlag is one of standard, party-protection or
+	// psv. If VerifyPrivate is set, the collector checks the private state of
+	// each private contract on every node after the run. See PrivateFor for
+	// the recipients.
+	PrivateFrom       string `mapstructure:"privatefrom"`
+	ThreadPrivateFrom string `mapstructure:"thread-privatefrom"`
+	PrivacyFlag       string `mapstructure:"privacy-flag"`
+	VerifyPrivate     bool   `mapstructure:"verify-private"`
+
+	// PrivateRatio, if between 0 and 1, interleaves public and private
+	// transactions with this fraction private. Each is tagged so the
+	// collector can report latency and throughput for both.
+	PrivateRatio float64 `mapstructure:"private-ratio"`
+
+	// HealthInterval, if not zero, is how often each node is probed with
+	// eth_blockNumber, net_peerCount and eth_syncing. If Failover is set,
+	// threads using a node which fails its probe move to a healthy node.
+	HealthInterval time.Duration `mapstructure:"health-interval"`
+	Failover       bool          `mapstructure:"failover"`
+}
+
+// TesseraAuth returns the credentials for the tessera endpoints
+func (cfg *Config) TesseraAuth() client.AuthConfig {
+	return client.AuthConfig{Token: cfg.TesseraAuthToken, JWTSecret: cfg.TesseraJWTSecret}
 }
 
 func NewConfigLoader() Config {
@@ -117,15 +194,35 @@ func (cfg *Config) SetDefaults() {
 	cfg.NumTransactions = 5000
 	cfg.TPS = 221
 	cfg.GasLimit = 60000
+	cfg.GasPrice = 0
+	cfg.SuggestGasPrice = false
 	cfg.PrivateFor = ""
+	cfg.PrivateFrom = ""
+	cfg.ThreadPrivateFrom = ""
+	cfg.PrivacyFlag = client.PrivacyStandard
+	cfg.VerifyPrivate = false
+	cfg.PrivateRatio = 0
+	cfg.ChainID = 0
 	cfg.SingleNode = false
+	cfg.Assign = AssignRoundRobin
+	cfg.AssignNodes = nil
 	cfg.CheckReceipts = false
+	cfg.ReceiptPollers = 0
 	cfg.StaticNodes = ""
 	cfg.BaseTesseraPort = 0
 	cfg.TesseraEndpoint = ""
+	cfg.TesseraAuthToken = ""
+	cfg.TesseraJWTSecret = ""
 	cfg.ExpectedLatency = 10 * time.Second
+	cfg.EstimateGas = false
+	cfg.GasMultiplier = 1.5
 	cfg.DeployGasLimit = 600000
 	cfg.DeployKey = ""
+	cfg.SaveContract = ""
+	cfg.Presign = false
+	cfg.BatchSize = 0
+	cfg.HealthInterval = 0
+	cfg.Failover = false
 	cfg.RunOne = false
 	cfg.CollectRate = 10 * time.Second
 }
@@ -138,17 +235,55 @@ type Loader struct {
 	collector     *collect.Collector
 	ConfigFileDir string
 
-	pb *client.TransactionProgress
+	pb      *client.TransactionProgress
+	metrics *client.Metrics
+	log     log.Logger
 
 	limiter *time.Ticker
+
+	// The nodes the load is spread across
+	endpoints []client.Endpoint
+	// The index in endpoints of the node for each thread
+	threadNode []int
+
+	// If HealthInterval is set, health probes each node periodically. If
+	// Failover is also set, threads move off unhealthy nodes to the others in
+	// failoverNodes.
+	health        *healthMonitor
+	failoverNodes []int
+
 	// One AccountSet per thread
 	accounts []client.AccountSet
 	// One connection per thread
 	ethC    []*client.Client
 	ethCUrl []string
 
-	contract ContractTransactor
-	address  common.Address
+	abi abi.ABI
+	// One contract binding per thread, each bound to the threads connection
+	contracts []ContractTransactor
+	address   common.Address
+
+	// If Presign is configured, the signed transactions for each thread
+	signed [][]SignedTx
+
+	gasReport *GasReport
+	results   *ResultReport
+	// If CheckReceipts is set, receipts checks the sent transactions
+	receipts *receiptPoller
+
+	// The EIP-155 chain id public transactions are signed for, nil if they
+	// are not replay protected
+	chainID *big.Int
+
+	// If PrivateFor is set, the recipient sets which transactions rotate
+	// through and the private contract instances they are sent to
+	privateFor  [][]string
+	privacyFlag engine.PrivacyFlagType
+	private     []*privateContract
+	// The tessera key each thread sends private transactions from, and the
+	// keys known to be held by each nodes tessera
+	threadPrivateFrom []string
+	nodeKeys          [][]string
 }
 
 func NewLoader(ctx context.Context, configFileDir string, r root.Runner, opts ...LoaderOption) (Loader, error) {
@@ -159,19 +294,24 @@ func NewLoader(ctx context.Context, configFileDir string, r root.Runner, opts ..
 		ConfigFileDir: configFileDir,
 		rootCfg:       r.GetParent().GetConfig().(*root.Config),
 		loadCfg:       r.GetParent().GetNamedConfig(r.GetName()).(*Config),
+		gasReport:     NewGasReport(),
+		results:       NewResultReport(),
+		log:           log.Root(),
 	}
 
 	// NumTransactions needs to be adjusted before processing the options (so the progress options can be correctly applied)
-	if delta := a.loadCfg.TruncateTargetTransactions(); delta != 0 {
-		fmt.Printf(
-			"adjusted target number of transactions from %d to %d\n",
-			a.loadCfg.NumTransactions+delta, a.loadCfg.NumTransactions)
-	}
+	delta := a.loadCfg.TruncateTargetTransactions()
 
 	for _, opt := range opts {
 		opt(&a)
 	}
 
+	// Logged after the options, which may set the logger
+	if delta != 0 {
+		a.log.Info("adjusted target number of transactions",
+			"from", a.loadCfg.NumTransactions+delta, "to", a.loadCfg.NumTransactions)
+	}
+
 	// Progress is disabled entirely if the WithProgress option is not
 	// supplied. This is most cleanly accomplished with NoOp progress
 	if a.pb == nil {
@@ -180,53 +320,146 @@ func NewLoader(ctx context.Context, configFileDir string, r root.Runner, opts ..
 
 	a.limiter = time.NewTicker(time.Second / time.Duration(a.loadCfg.TPS))
 
-	var tx *types.Transaction
+	a.abi, err = abi.JSON(strings.NewReader(GetSetAddABI))
+	if err != nil {
+		return Loader{}, err
+	}
 
-	var deployKey *ecdsa.PrivateKey
-	parsed, err := abi.JSON(strings.NewReader(GetSetAddABI))
+	a.endpoints, err = ResolveEndpoints(a.ConfigFileDir, a.rootCfg, a.loadCfg)
 	if err != nil {
 		return Loader{}, err
 	}
+	if err = a.dialClients(); err != nil {
+		return Loader{}, err
+	}
 
-	if a.loadCfg.DeployKey != "" {
-		deployKey, err = crypto.HexToECDSA(a.loadCfg.DeployKey)
-		if err != nil {
+	if a.loadCfg.Failover && a.loadCfg.HealthInterval == 0 {
+		a.loadCfg.HealthInterval = 5 * time.Second
+	}
+	if a.loadCfg.HealthInterval != 0 {
+		if a.health, err = newHealthMonitor(&a); err != nil {
 			return Loader{}, err
 		}
+		a.failoverNodes = distinctNodes(a.threadNode)
 	}
-	if deployKey == nil {
-		// This will likely fail as normal quorum requires balance to deploy
-		// event tho gasprice is 0
-		deployKey, err = crypto.GenerateKey()
+
+	if a.loadCfg.SuggestGasPrice {
+		if err = a.suggestGasPrice(ctx); err != nil {
+			return Loader{}, err
+		}
+	}
+	if err = checkFunding(a.loadCfg); err != nil {
+		return Loader{}, err
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, a.rootCfg.ClientTimeout)
+	a.chainID, err = client.ResolveChainID(rctx, a.ethC[0].Client, a.loadCfg.ChainID)
+	cancel()
+	if err != nil {
+		return Loader{}, err
+	}
+	if a.chainID != nil {
+		a.log.Info("signing for chain id", "chainid", a.chainID)
+	} else {
+		a.log.Info("signing without replay protection (homestead)")
+	}
+
+	a.accounts = make([]client.AccountSet, a.loadCfg.Threads)
+	for i := 0; i < a.loadCfg.Threads; i++ {
+
+		a.log.Debug("building account set", "thread", i, "node", a.ethCUrl[i])
+
+		a.accounts[i], err = client.NewAccountSet(ctx, a.ethC[i].Client, &a.loadCfg.AccountConfig, a.chainID, a.loadCfg.ThreadAccounts)
 		if err != nil {
 			return Loader{}, err
 		}
 	}
 
+	a.privateFor = client.ParsePrivateFor(a.loadCfg.PrivateFor)
+	if a.privacyFlag, err = client.ParsePrivacyFlag(a.loadCfg.PrivacyFlag); err != nil {
+		return Loader{}, err
+	}
+	a.threadPrivateFrom, a.nodeKeys, err = resolvePrivateFrom(a.loadCfg.ThreadPrivateFrom, a.endpoints, a.threadNode)
+	if err != nil {
+		return Loader{}, err
+	}
+
+	if a.loadCfg.PrivateRatio < 0 || a.loadCfg.PrivateRatio > 1 {
+		return Loader{}, fmt.Errorf("--private-ratio must be between 0 and 1")
+	}
+	if a.loadCfg.PrivateRatio != 0 && len(a.privateFor) == 0 {
+		return Loader{}, fmt.Errorf("--private-ratio requires --privatefor")
+	}
+
 	switch {
-	case a.rootCfg.EthEndpoint != "":
-		if err = a.clientsFromEthEndpoint(ctx); err != nil {
+	case len(a.privateFor) != 0:
+		if a.rootCfg.ContractAddress != "" {
+			return Loader{}, fmt.Errorf("--contract-address can not be used with --privatefor")
+		}
+		if a.loadCfg.VerifyPrivate {
+			// The collector records the private transactions, and checks
+			// them, so a standalone collect can do the same
+			if a.collector == nil {
+				return Loader{}, fmt.Errorf("--verify-private needs the collector, set --dbsource")
+			}
+			a.collector.TrackPrivate()
+		}
+		if err = a.deployPrivateContracts(ctx); err != nil {
 			return Loader{}, err
 		}
-	case a.loadCfg.StaticNodes != "":
-		if err = a.clientsFromStaticNodes(ctx); err != nil {
+		if a.isMixed() {
+			// The public transactions need a public contract
+			if err = a.deployContract(ctx); err != nil {
+				return Loader{}, err
+			}
+		}
+	case a.rootCfg.ContractAddress != "":
+		if err = a.useDeployedContract(ctx); err != nil {
 			return Loader{}, err
 		}
 	default:
-		return Loader{}, fmt.Errorf("you must provide either --ethendpoint or --staticnodes")
+		if err = a.deployContract(ctx); err != nil {
+			return Loader{}, err
+		}
 	}
 
-	deployAuth := bind.NewKeyedTransactor(deployKey)
+	a.contracts = make([]ContractTransactor, a.loadCfg.Threads)
+	for i := 0; i < a.loadCfg.Threads; i++ {
+		a.contracts[i] = a.newContractBinding(a.ethC[i], a.threadNode[i], a.threadPrivateFrom[i])
+	}
 
-	deployAuth.GasLimit = uint64(a.loadCfg.DeployGasLimit)
-	deployAuth.GasPrice = big0
-	a.address, tx, a.contract, err = bind.DeployContract(
-		deployAuth, parsed, common.FromHex(GetSetAddBin), a.ethC[0])
-	if err != nil {
+	if a.loadCfg.EstimateGas && len(a.privateFor) != 0 {
+		// The payload is only known to the parties, there is nothing to
+		// estimate against
+		a.log.Warn("estimate-gas is ignored for private transactions", "gaslimit", a.loadCfg.GasLimit)
+	} else if a.loadCfg.EstimateGas {
+		gasLimit, err := a.estimateMethodGas(ctx, "add", big.NewInt(2))
+		if err != nil {
+			return Loader{}, err
+		}
+		a.log.Info("using estimated gas limit for add", "gaslimit", gasLimit)
+		a.loadCfg.GasLimit = gasLimit
+		for i := range a.accounts {
+			for _, auth := range a.accounts[i].Auth {
+				auth.GasLimit = gasLimit
+			}
+		}
+	}
+
+	// After any gas estimate, so the accounts are funded for the gas limit
+	// actually used
+	if err = a.fundAccounts(ctx); err != nil {
 		return Loader{}, err
 	}
-	if ok := client.CheckReceipt(a.ethC[0].Client, tx, a.rootCfg.Retries, a.loadCfg.ExpectedLatency); !ok {
-		return Loader{}, fmt.Errorf("failed to deploy contract")
+
+	if a.loadCfg.BatchSize > 1 && !a.loadCfg.Presign {
+		a.log.Info("batch-size requires presigned transactions, enabling presign", "batchsize", a.loadCfg.BatchSize)
+		a.loadCfg.Presign = true
+	}
+	if a.loadCfg.Presign {
+		if err = a.presign(); err != nil {
+			return Loader{}, err
+		}
 	}
 
 	// num-tx / num-threads
@@ -234,25 +467,161 @@ func NewLoader(ctx context.Context, configFileDir string, r root.Runner, opts ..
 	return a, nil
 }
 
+// deployContract deploys a fresh instance of the contract using the first
+// client. If SaveContract is configured the address is written to that file.
+func (a *Loader) deployContract(ctx context.Context) error {
+
+	var tx *types.Transaction
+
+	deployKey, err := a.deployKey()
+	if err != nil {
+		return err
+	}
+
+	deployAuth := client.NewKeyedTransactor(deployKey, a.chainID)
+
+	deployAuth.GasLimit = uint64(a.loadCfg.DeployGasLimit)
+	deployAuth.GasPrice = new(big.Int).SetUint64(a.loadCfg.GasPrice)
+	if a.loadCfg.EstimateGas {
+		deployAuth.GasLimit, err = a.estimateDeployGas(ctx, deployAuth.From, common.FromHex(GetSetAddBin))
+		if err != nil {
+			return err
+		}
+		a.log.Info("using estimated gas limit for deploy", "gaslimit", deployAuth.GasLimit)
+	}
+	a.address, tx, _, err = bind.DeployContract(
+		deployAuth, a.abi, common.FromHex(GetSetAddBin), a.ethC[0])
+	if err != nil {
+		return err
+	}
+	receipt, err := client.WaitReceipt(a.ethC[0].Client, tx, a.rootCfg.Retries, a.loadCfg.ExpectedLatency)
+	if err != nil || receipt.Status != 1 {
+		return fmt.Errorf("failed to deploy contract")
+	}
+	a.gasReport.Add("deploy", tx, receipt)
+
+	a.log.Info("deployed contract", "contract", a.address.Hex(), "block", receipt.BlockNumber)
+
+	if a.loadCfg.SaveContract != "" {
+		fileName := a.loadCfg.SaveContract
+		if !filepath.IsAbs(fileName) {
+			fileName = filepath.Join(a.ConfigFileDir, fileName)
+		}
+		if err = client.SaveContractAddress(fileName, a.address, receipt.BlockNumber.Uint64()); err != nil {
+			return fmt.Errorf("saving contract address to `%s': %w", fileName, err)
+		}
+		a.log.Info("saved contract address", "file", fileName)
+	}
+	return nil
+}
+
+// deployKey returns the configured DeployKey, or a fresh key if there is none
+func (a *Loader) deployKey() (*ecdsa.PrivateKey, error) {
+	if a.loadCfg.DeployKey != "" {
+		return crypto.HexToECDSA(a.loadCfg.DeployKey)
+	}
+	// This will likely fail as normal quorum requires balance to deploy
+	// event tho gasprice is 0
+	return crypto.GenerateKey()
+}
+
+// useDeployedContract binds to the contract at the configured ContractAddress
+// instead of deploying. It is an error if there is no code at the address.
+func (a *Loader) useDeployedContract(ctx context.Context) error {
+
+	var err error
+
+	a.address, err = client.ParseContractAddress(a.ConfigFileDir, a.rootCfg.ContractAddress)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.rootCfg.ClientTimeout)
+	defer cancel()
+	if err = client.CheckContractCode(ctx, a.ethC[0].Client, a.address); err != nil {
+		return err
+	}
+	a.log.Info("using deployed contract", "contract", a.address.Hex())
+	return nil
+}
+
 func (a *Loader) Run() {
 
 	var wg sync.WaitGroup
 
+	if a.health != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go a.health.Run(ctx)
+	}
+
 	if a.collector != nil {
+		switch {
+		case a.isMixed():
+			a.collector.SetContractAddress(append(a.privateAddresses(), a.address)...)
+			a.collector.TrackClasses()
+		case len(a.private) != 0:
+			a.collector.SetContractAddress(a.privateAddresses()...)
+		case a.rootCfg.ContractAddress == "":
+			// The collector already has it otherwise
+			a.collector.SetContractAddress(a.address)
+		}
+		// Connections to the nodes without threads are only made if the
+		// collector samples them
+		if a.collector.SamplesNodes() {
+			names, nodes, err := a.nodeClients()
+			if err != nil {
+				a.log.Error("connecting collector to the nodes", "err", err)
+			} else {
+				a.collector.SetNodes(names, nodes)
+			}
+		}
 		wg.Add(1)
 		go a.collector.Collect(a.ethC[0], &wg, fmt.Sprintf("client-%d", 0), 0)
 	}
 
+	if a.loadCfg.CheckReceipts {
+		pollers := a.loadCfg.ReceiptPollers
+		if pollers <= 0 {
+			pollers = a.loadCfg.Threads
+		}
+		a.receipts = newReceiptPoller(a, pollers)
+	}
+
 	for i := 0; i < a.loadCfg.Threads; i++ {
 		wg.Add(1)
 		clientId, addr := fmt.Sprintf("client-%d", i), a.ethCUrl[i]
-		fmt.Printf("client thread: %s, node addr:%s\n", clientId, addr)
+		a.log.Info("starting thread", "thread", i, "node", addr)
+		if a.loadCfg.BatchSize > 1 {
+			go a.batchAdder(&wg, clientId, i)
+			continue
+		}
 		go a.adder(a.ethC[i].Client, &wg, clientId, i)
 	}
 
 	wg.Wait()
+	if a.receipts != nil {
+		a.receipts.Close()
+	}
 	if a.pb.IsEnabled() {
-		fmt.Printf("sent: %d, mined: %d\n", a.pb.CurrentIssued(), a.pb.CurrentMined())
+		a.log.Info("load complete", "sent", a.pb.CurrentIssued(), "mined", a.pb.CurrentMined())
+	}
+	a.gasReport.Print()
+	if a.receipts != nil {
+		a.receipts.Print()
+	}
+	a.results.Print()
+	a.printFailovers()
+	if a.collector != nil {
+		a.collector.PrintClasses()
+		a.collector.PrintTraceGas()
+		a.collector.PrintTxPool()
+	}
+
+	if a.loadCfg.VerifyPrivate && len(a.private) != 0 {
+		if err := a.verifyPrivate(); err != nil {
+			a.log.Error("verifying private state", "err", err)
+		}
 	}
 }
 
@@ -274,34 +643,60 @@ func (lo *Loader) RunOne() error {
 	auth.Nonce = big.NewInt(int64(nonce))
 
 	var tx *types.Transaction
-	tx, err = lo.contract.Transact(auth, "add", big.NewInt(3))
+	tx, err = lo.contracts[0].Transact(auth, "add", big.NewInt(3))
 	if err != nil {
 		return err
 	}
-	if ok := client.CheckReceipt(ethC.Client, tx, lo.rootCfg.Retries, lo.rootCfg.ClientTimeout); !ok {
+	r, err := client.WaitReceipt(ethC.Client, tx, lo.rootCfg.Retries, lo.rootCfg.ClientTimeout)
+	if err == nil && r.Status != types.ReceiptStatusSuccessful {
+		ctx, cancel := context.WithTimeout(context.Background(), lo.rootCfg.ClientTimeout)
+		reason, rerr := client.RevertReason(ctx, ethC.Client, tx, r)
+		cancel()
+		if rerr != nil {
+			return fmt.Errorf("transaction %s reverted: %w", tx.Hash().Hex(), rerr)
+		}
+		return fmt.Errorf("transaction %s reverted: %s", tx.Hash().Hex(), reason)
+	}
+	if err != nil {
 		return fmt.Errorf("transaction %s failed or not completed in %v", tx.Hash().Hex(), lo.rootCfg.ClientTimeout)
 	}
+	lo.gasReport.Add("add", tx, r)
+	lo.gasReport.Print()
 	return nil
 }
 
+// sendSigned submits the n'th presigned transaction for the thread
+func (lo *Loader) sendSigned(ias, n int) (*types.Transaction, error) {
+
+	signed := lo.signed[ias][n]
+
+	ctx, cancel := context.WithTimeout(context.Background(), lo.rootCfg.ClientTimeout)
+	defer cancel()
+	if err := client.SendRawTransaction(ctx, lo.ethC[ias].RPC, signed.Raw); err != nil {
+		return nil, err
+	}
+	return signed.Tx, nil
+}
+
 func (lo *Loader) adder(ethC *ethclient.Client, wg *sync.WaitGroup, banner string, ias int) {
 
 	defer wg.Done()
 
 	var tx *types.Transaction
 
+	l := lo.threadLogger(ias)
+
 	// Note: NumTransactions is adjusted by TruncateTargetTransactions so
 	// everything works out as whole numbers. And so that
 	//  NumTransactions >= lo.cfg.NumThreads * lo.cfg.AccountsPerThread
 	numBatches := lo.loadCfg.NumTransactions / (lo.loadCfg.Threads * lo.loadCfg.ThreadAccounts)
 
-	// Each batch issues on tx per account. The batching is only worth it if
-	// CheckBatchReciepts is true (it cleans up the picture by reducing the eth
-	// rpc load  on the node)
-	batch := make([]*types.Transaction, lo.loadCfg.ThreadAccounts)
-
 	var err error
 
+	// The number of private transactions the thread has sent, for rotating
+	// through the PrivateFor sets
+	var private int
+
 	// updateNonce := func(ias, i int) {
 	// 	var nonce uint64
 	// 	ctx, cancel := context.WithTimeout(context.Background(), lo.rootCfg.ClientTimeout)
@@ -321,7 +716,7 @@ func (lo *Loader) adder(ethC *ethclient.Client, wg *sync.WaitGroup, banner strin
 	for r := 0; r < numBatches; r++ {
 
 		if !lo.pb.IsEnabled() {
-			fmt.Printf("%s: batch %d, node %s\n", banner, r, lo.ethCUrl[ias])
+			l.Info("batch", "batch", r)
 		}
 
 		for i := 0; i < lo.loadCfg.ThreadAccounts; i++ {
@@ -329,217 +724,171 @@ func (lo *Loader) adder(ethC *ethclient.Client, wg *sync.WaitGroup, banner strin
 				<-lo.limiter.C
 			}
 
-			// Set the ctx for the auth
-			ctx, cancel := lo.accounts[ias].WithTimeout(context.Background(), lo.rootCfg.ClientTimeout, i)
-			lo.accounts[ias].Auth[i].Context = ctx
-			tx, err = lo.contract.Transact(lo.accounts[ias].Auth[i], "add", big.NewInt(2))
-			cancel()
+			lo.failover(ias)
+
+			// Rotate through the recipient sets. In mixed mode, public
+			// transactions have none.
+			n := r*lo.loadCfg.ThreadAccounts + i
+			if len(lo.privateFor) != 0 {
+				lo.accounts[ias].Auth[i].PrivateFor = lo.privateForTx(n, private)
+				if lo.accounts[ias].Auth[i].PrivateFor != nil {
+					private++
+				}
+			}
+
+			if lo.signed != nil {
+				tx, err = lo.sendSigned(ias, r*lo.loadCfg.ThreadAccounts+i)
+			} else {
+				// Set the ctx for the auth
+				ctx, cancel := lo.accounts[ias].WithTimeout(context.Background(), lo.rootCfg.ClientTimeout, i)
+				lo.accounts[ias].Auth[i].Context = ctx
+				tx, err = lo.contracts[ias].Transact(lo.accounts[ias].Auth[i], "add", big.NewInt(2))
+				cancel()
+			}
 			if err != nil {
-				fmt.Printf("client for %s. error from transact: %v\n", lo.ethCUrl[ias], err)
+				l.Error("transact", "err", err)
+				lo.metrics.Errored(err)
+				lo.results.Add(lo.threadNodeName(ias), client.SendResult(err))
 				// updateNonce(ias, i)
 				continue
 			}
 			lo.pb.IssuedIncrement()
-			// updateNonce(ias, i)
-			if lo.loadCfg.AccountConfig.MangeNonce {
-				lo.accounts[ias].IncNonce(i)
+			lo.metrics.Issued(1)
+			if lo.receipts != nil {
+				lo.receipts.Submit(lo.ethC[ias].Client, lo.threadNodeName(ias), tx)
+			} else {
+				lo.results.Add(lo.threadNodeName(ias), client.TxSent)
 			}
-
-			batch[i] = tx
-		}
-		if lo.loadCfg.CheckReceipts {
-			for i := 0; i < lo.loadCfg.ThreadAccounts; i++ {
-				if ok := client.CheckReceipt(ethC, batch[i], lo.rootCfg.Retries, lo.loadCfg.ExpectedLatency); !ok {
-					fmt.Printf("terminating client for %s. no valid receipt found for tx: %s\n",
-						lo.ethCUrl[ias], tx.Hash().Hex())
+			// The classes are only compared in mixed runs. Otherwise the
+			// collector only needs the transactions for the inclusion metric.
+			if lo.collector != nil && (lo.isMixed() || lo.metrics != nil) {
+				class := "public"
+				if lo.isPrivateTx(n) {
+					class = "private"
 				}
+				lo.collector.TxSent(tx.Hash(), class)
+			}
+			// updateNonce(ias, i)
+			if lo.loadCfg.AccountConfig.MangeNonce && lo.signed == nil {
+				lo.accounts[ias].IncNonce(i)
 			}
 		}
 	}
 }
 
-func (a *Loader) resolveHost(host string) (string, error) {
-	if !a.rootCfg.ResolveHosts {
-		return host, nil
-	}
-	addrs, err := net.LookupIP(host)
-	if err != nil {
-		return "", err
-	}
-	if len(addrs) != 1 {
-		return "", fmt.Errorf("cant resolve ambigous host. could be any of: %v", addrs)
-	}
-	return addrs[0].String(), nil
+// threadNodeName returns the name of the node thread ias is currently using
+func (lo *Loader) threadNodeName(ias int) string {
+	return lo.endpoints[lo.threadNode[ias]].Name
 }
 
-func (a *Loader) clientsFromEthEndpoint(ctx context.Context) error {
-
-	if a.rootCfg.EthEndpoint == "" {
-		return fmt.Errorf("ethendpoint is empty")
-	}
+// threadLogger returns the logger for thread ias. The node is looked up for
+// each record as failover can move the thread.
+func (lo *Loader) threadLogger(ias int) log.Logger {
+	return lo.log.New("thread", ias, "node", log.Lazy{Fn: func() string { return lo.ethCUrl[ias] }})
+}
 
-	nodes := a.loadCfg.Nodes
-	if nodes == 0 {
-		nodes = a.loadCfg.Threads
-	}
+// suggestGasPrice asks the first node for its gas price (eth_gasPrice) and
+// replaces the configured GasPrice with the result. On networks with a base
+// fee this is the base fee plus a tip, which legacy transactions must meet.
+func (a *Loader) suggestGasPrice(ctx context.Context) error {
 
-	a.accounts = make([]client.AccountSet, a.loadCfg.Threads)
-	a.ethC = make([]*client.Client, a.loadCfg.Threads)
-	a.ethCUrl = make([]string, a.loadCfg.Threads)
+	ctx, cancel := context.WithTimeout(ctx, a.rootCfg.ClientTimeout)
+	defer cancel()
 
-	qu, err := url.Parse(a.rootCfg.EthEndpoint)
+	price, err := suggestedGasPrice(ctx, a.ethC[0])
 	if err != nil {
 		return err
 	}
+	a.log.Info("using suggested gas price", "gasprice", price)
+	a.loadCfg.GasPrice = price
+	return nil
+}
 
-	quHostname, err := a.resolveHost(qu.Hostname())
+func suggestedGasPrice(ctx context.Context, ethC *client.Client) (uint64, error) {
+	price, err := ethC.SuggestGasPrice(ctx)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("eth_gasPrice: %w", err)
 	}
-
-	baseQuorumPort := a.rootCfg.BasePort
-	if baseQuorumPort == 0 {
-		baseQuorumPort, err = strconv.Atoi(qu.Port())
-		if err != nil {
-			return err
-		}
+	if !price.IsUint64() {
+		return 0, fmt.Errorf("suggested gas price %v is out of range", price)
 	}
+	return price.Uint64(), nil
+}
 
-	var tu *url.URL
-	var tuHostname string
-	var baseTesseraPort int
-	if a.loadCfg.TesseraEndpoint != "" {
-		tu, err = url.Parse(a.loadCfg.TesseraEndpoint)
-		if err != nil {
-			return err
-		}
-
-		tuHostname, err = a.resolveHost(tu.Hostname())
-		if err != nil {
-			return err
-		}
+// clientOptions returns the options for connecting to the eth and tessera
+// endpoints of ep
+func (a *Loader) clientOptions(ep client.Endpoint) []client.ClientOption {
+	return endpointClientOptions(a.rootCfg, a.loadCfg, ep)
+}
 
-		baseTesseraPort, err = strconv.Atoi(tu.Port())
-		if err != nil {
-			return err
-		}
+func endpointClientOptions(rootCfg *root.Config, loadCfg *Config, ep client.Endpoint) []client.ClientOption {
+	return []client.ClientOption{
+		client.WithTransport(rootCfg.TransportConfig),
+		client.WithAuth(ep.Auth(rootCfg.EthAuth())),
+		client.WithTesseraAuth(ep.TesseraAuth(loadCfg.TesseraAuth())),
+		client.WithHost(ep.EthHost),
+		client.WithTesseraHost(ep.TesseraHost),
 	}
+}
 
-	for i := 0; i < a.loadCfg.Threads; i++ {
+// nodeClients returns the name of each node and a client for it. The client
+// of the first thread assigned to a node is used, a client is created for
+// nodes which have no threads.
+func (a *Loader) nodeClients() ([]string, []*client.Client, error) {
 
-		qu.Host = fmt.Sprintf("%s:%d", quHostname, baseQuorumPort)
-		if !a.loadCfg.SingleNode && nodes > 1 {
-			qu.Host = fmt.Sprintf("%s:%d", quHostname, baseQuorumPort+(i%(nodes)))
-		}
-		var tuEndpoint string
-		if tu != nil && nodes > 1 {
-			tu.Host = fmt.Sprintf("%s:%d", tuHostname, baseTesseraPort+(i%(nodes)))
-			tuEndpoint = tu.String()
+	names := make([]string, len(a.endpoints))
+	nodes := make([]*client.Client, len(a.endpoints))
+	for i, node := range a.threadNode {
+		if nodes[node] == nil {
+			nodes[node] = a.ethC[i]
 		}
+	}
 
-		a.ethC[i], err = client.NewClient(qu.String(), tuEndpoint, a.rootCfg.ClientTimeout)
-		if err != nil {
-			return err
+	var err error
+	for i, ep := range a.endpoints {
+		names[i] = ep.Name
+		if nodes[i] != nil {
+			continue
 		}
-		a.ethCUrl[i] = qu.String()
-
-		fmt.Printf("building account set for client[%d]: %s\n", i, qu.String())
-
-		a.accounts[i], err = client.NewAccountSet(ctx, a.ethC[i].Client, &a.loadCfg.AccountConfig, a.loadCfg.ThreadAccounts)
-		if err != nil {
-			return err
+		if nodes[i], err = client.NewClient(ep.Eth, "", a.rootCfg.ClientTimeout, a.clientOptions(ep)...); err != nil {
+			return nil, nil, fmt.Errorf("connecting to %s (%s): %w", ep.Name, ep.Eth, err)
 		}
 	}
-	return nil
+	return names, nodes, nil
 }
 
-func (a *Loader) clientsFromStaticNodes(ctx context.Context) error {
-	if a.loadCfg.StaticNodes == "" {
-		return fmt.Errorf("staticnodes is empty")
+// assignThreads returns the node for each thread according to the --assign
+// options, or the first node for every thread if SingleNode is set
+func assignThreads(loadCfg *Config, endpoints []client.Endpoint) ([]int, error) {
+	if loadCfg.SingleNode {
+		return make([]int, loadCfg.Threads), nil
 	}
+	return AssignThreads(loadCfg.Assign, loadCfg.AssignNodes, endpoints, loadCfg.Threads)
+}
 
-	fileName := filepath.Join(a.ConfigFileDir, a.loadCfg.StaticNodes)
-	var staticNodes []string
-	if err := common.LoadJSON(fileName, &staticNodes); err != nil {
-		return fmt.Errorf("loading file `%s': %v", fileName, err)
-	}
+// dialClients creates one client connection per thread. Threads are assigned
+// to the endpoints according to the Assign strategy, or all to the first if
+// SingleNode is set.
+func (a *Loader) dialClients() error {
 
-	nodes := a.loadCfg.Nodes
-	if nodes == 0 {
-		nodes = a.loadCfg.Threads
-	}
+	var err error
 
-	if nodes > len(staticNodes) {
-		return fmt.Errorf(
-			"to few nodes in %s. need %d, have %d", a.loadCfg.StaticNodes, nodes, len(staticNodes))
+	if a.threadNode, err = assignThreads(a.loadCfg, a.endpoints); err != nil {
+		return err
 	}
 
-	a.accounts = make([]client.AccountSet, a.loadCfg.Threads)
 	a.ethC = make([]*client.Client, a.loadCfg.Threads)
 	a.ethCUrl = make([]string, a.loadCfg.Threads)
 
-	quorumPort := a.rootCfg.BasePort
-	if quorumPort == 0 {
-		quorumPort = 8545
-	}
-	tesseraPort := a.loadCfg.BaseTesseraPort
-	if tesseraPort == 0 {
-		tesseraPort = 50000
-	}
-
-	var err error
-	qurls := make([]url.URL, len(staticNodes))
-	turls := make([]url.URL, len(staticNodes))
-	for i := 0; i < len(staticNodes); i++ {
-		qu, err := url.Parse(staticNodes[i])
-		if err != nil {
-			return err
-		}
-
-		// Ignore the port in the file. If its an actual static-nodes.json it
-		// will be the p2p port
-		parts := strings.Split(qu.Host, ":")
-		parts[len(parts)-1] = strconv.Itoa(quorumPort)
-		qurls[i].Scheme = "http"
-		parts[0], err = a.resolveHost(parts[0])
-		if err != nil {
-			return err
-		}
-		qurls[i].Host = strings.Join(parts, ":")
-
-		parts[len(parts)-1] = strconv.Itoa(tesseraPort)
-		turls[i].Scheme = "http"
-		turls[i].Host = strings.Join(parts, ":")
-	}
-
 	for i := 0; i < a.loadCfg.Threads; i++ {
 
-		qu, tu := qurls[0], turls[0]
-
-		if !a.loadCfg.SingleNode && nodes > 1 {
-			qu = qurls[i%(nodes)]
-			tu = turls[i%(nodes)]
-		}
-
-		quEndpoint := qu.String()
-		tuEndpoint := ""
-		if a.loadCfg.BaseTesseraPort != 0 {
-			// TODO: Better handling of tessera
-			tuEndpoint = tu.String()
-		}
+		ep := a.endpoints[a.threadNode[i]]
 
-		a.ethC[i], err = client.NewClient(quEndpoint, tuEndpoint, a.rootCfg.ClientTimeout)
+		a.ethC[i], err = client.NewClient(ep.Eth, ep.Tessera, a.rootCfg.ClientTimeout, a.clientOptions(ep)...)
 		if err != nil {
-			return err
-		}
-		a.ethCUrl[i] = quEndpoint
-
-		fmt.Printf("building account set for client[%d]: %s\n", i, quEndpoint)
-
-		a.accounts[i], err = client.NewAccountSet(ctx, a.ethC[i].Client, &a.loadCfg.AccountConfig, a.loadCfg.ThreadAccounts)
-		if err != nil {
-			return err
+			return fmt.Errorf("connecting to %s (%s): %w", ep.Name, ep.Eth, err)
 		}
+		a.ethCUrl[i] = ep.Eth
 	}
 	return nil
 }
@@ -0,0 +1,188 @@
+package load
+
+import (
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/robinbryce/benchblock/bbeth/client"
+)
+
+// fakeNode is an in process node providing just enough of the eth api for
+// the loader
+type fakeNode struct {
+	mu sync.Mutex
+
+	// down fails eth_blockNumber, as if the node was unreachable
+	down        bool
+	blockNumber uint64
+	peers       uint64
+	// syncing, if set, is the highest block the node is syncing to
+	syncing uint64
+
+	chainID    uint64
+	balance    uint64
+	gasPrice   uint64
+	deployCost uint64
+
+	// reject is the error for transactions which should not be accepted
+	reject map[common.Hash]error
+	sent   []common.Hash
+	// sentTxs are the accepted transactions. If mine is set, each is given a
+	// successful receipt as it is accepted.
+	sentTxs []*types.Transaction
+	mine    bool
+	// nonce is the pending nonce for every account
+	nonce uint64
+
+	// receipts are returned by eth_getTransactionReceipt, transactions
+	// without one are not found
+	receipts map[common.Hash]*types.Receipt
+	// callErr is the error for every eth_call, and calledAt records the block
+	// each was made against
+	callErr  error
+	calledAt []int64
+}
+
+type fakeEthAPI struct{ n *fakeNode }
+
+func (api *fakeEthAPI) SendRawTransaction(raw hexutil.Bytes) (common.Hash, error) {
+	var tx types.Transaction
+	if err := rlp.DecodeBytes(raw, &tx); err != nil {
+		return common.Hash{}, err
+	}
+	api.n.mu.Lock()
+	defer api.n.mu.Unlock()
+	if err := api.n.reject[tx.Hash()]; err != nil {
+		return common.Hash{}, err
+	}
+	api.n.sent = append(api.n.sent, tx.Hash())
+	api.n.sentTxs = append(api.n.sentTxs, &tx)
+	if api.n.mine {
+		if api.n.receipts == nil {
+			api.n.receipts = map[common.Hash]*types.Receipt{}
+		}
+		api.n.receipts[tx.Hash()] = &types.Receipt{
+			Status: types.ReceiptStatusSuccessful, TxHash: tx.Hash(), GasUsed: tx.Gas(),
+			CumulativeGasUsed: tx.Gas(), Logs: []*types.Log{}, BlockNumber: big.NewInt(1)}
+	}
+	return tx.Hash(), nil
+}
+
+func (api *fakeEthAPI) BlockNumber() (hexutil.Uint64, error) {
+	api.n.mu.Lock()
+	defer api.n.mu.Unlock()
+	if api.n.down {
+		return 0, errors.New("connection refused")
+	}
+	return hexutil.Uint64(api.n.blockNumber), nil
+}
+
+func (api *fakeEthAPI) Syncing() (interface{}, error) {
+	api.n.mu.Lock()
+	defer api.n.mu.Unlock()
+	if api.n.syncing == 0 {
+		return false, nil
+	}
+	return map[string]hexutil.Uint64{
+		"currentBlock": hexutil.Uint64(api.n.blockNumber), "highestBlock": hexutil.Uint64(api.n.syncing)}, nil
+}
+
+func (api *fakeEthAPI) ChainId() hexutil.Uint64 {
+	api.n.mu.Lock()
+	defer api.n.mu.Unlock()
+	return hexutil.Uint64(api.n.chainID)
+}
+
+func (api *fakeEthAPI) GetBlockByNumber(number rpc.BlockNumber, full bool) (*types.Header, error) {
+	// Every node has the same chain
+	return &types.Header{Number: big.NewInt(number.Int64()), Difficulty: new(big.Int)}, nil
+}
+
+func (api *fakeEthAPI) GetBalance(address common.Address, number rpc.BlockNumber) *hexutil.Big {
+	api.n.mu.Lock()
+	defer api.n.mu.Unlock()
+	return (*hexutil.Big)(new(big.Int).SetUint64(api.n.balance))
+}
+
+func (api *fakeEthAPI) GasPrice() *hexutil.Big {
+	api.n.mu.Lock()
+	defer api.n.mu.Unlock()
+	return (*hexutil.Big)(new(big.Int).SetUint64(api.n.gasPrice))
+}
+
+func (api *fakeEthAPI) EstimateGas(args map[string]interface{}) hexutil.Uint64 {
+	api.n.mu.Lock()
+	defer api.n.mu.Unlock()
+	return hexutil.Uint64(api.n.deployCost)
+}
+
+func (api *fakeEthAPI) GetTransactionCount(address common.Address, number rpc.BlockNumber) hexutil.Uint64 {
+	api.n.mu.Lock()
+	defer api.n.mu.Unlock()
+	return hexutil.Uint64(api.n.nonce)
+}
+
+func (api *fakeEthAPI) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	api.n.mu.Lock()
+	defer api.n.mu.Unlock()
+	return api.n.receipts[hash], nil
+}
+
+func (api *fakeEthAPI) Call(args map[string]interface{}, number rpc.BlockNumber) (hexutil.Bytes, error) {
+	api.n.mu.Lock()
+	defer api.n.mu.Unlock()
+	api.n.calledAt = append(api.n.calledAt, number.Int64())
+	return hexutil.Bytes{}, api.n.callErr
+}
+
+type fakeNetAPI struct{ n *fakeNode }
+
+func (api *fakeNetAPI) PeerCount() hexutil.Uint64 {
+	api.n.mu.Lock()
+	defer api.n.mu.Unlock()
+	return hexutil.Uint64(api.n.peers)
+}
+
+// set updates the node state under its lock
+func (n *fakeNode) set(fn func(n *fakeNode)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	fn(n)
+}
+
+func (n *fakeNode) server(t *testing.T) *rpc.Server {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("eth", &fakeEthAPI{n: n}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.RegisterName("net", &fakeNetAPI{n: n}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+	return srv
+}
+
+// dial starts the node and returns a client connected to it in process
+func (n *fakeNode) dial(t *testing.T) *client.Client {
+	c := rpc.DialInProc(n.server(t))
+	t.Cleanup(c.Close)
+	return &client.Client{Client: ethclient.NewClient(c), RPC: c}
+}
+
+// serve starts the node on http and returns its url
+func (n *fakeNode) serve(t *testing.T) string {
+	srv := httptest.NewServer(n.server(t))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+var errNonceTooLow = errors.New("nonce too low")
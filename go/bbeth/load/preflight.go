@@ -0,0 +1,280 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/robinbryce/benchblock/bbeth/root"
+)
+
+// preflightNode is what the preflight checks learned about a single node
+type preflightNode struct {
+	ep      client.Endpoint
+	ethC    *client.Client
+	chainID *big.Int
+	health  NodeHealth
+	failed  []string
+}
+
+func (n *preflightNode) fail(format string, args ...interface{}) {
+	n.failed = append(n.failed, fmt.Sprintf(format, args...))
+}
+
+// Preflight checks every node the load would be sent to, resolving the nodes
+// and assigning threads to them exactly as NewLoader does. Each node must be
+// reachable, agree on the chain id, have peers, not be syncing and be within
+// maxHeadLag blocks of the highest head. If PrivateFor is set each tessera
+// must be up. If a DeployKey is configured, and no ContractAddress, its
+// balance must cover the deploy. The result for each node is printed and an
+// error is returned if any check failed.
+func Preflight(ctx context.Context, cfgDir string, rootCfg *root.Config, loadCfg *Config, maxHeadLag uint64) error {
+
+	return preflight(ctx, cfgDir, rootCfg, loadCfg, maxHeadLag, rootCfg.HostResolver())
+}
+
+func preflight(
+	ctx context.Context, cfgDir string, rootCfg *root.Config, loadCfg *Config, maxHeadLag uint64,
+	resolver client.HostResolver) error {
+
+	endpoints, err := resolveEndpoints(cfgDir, rootCfg, loadCfg, resolver)
+	if err != nil {
+		return err
+	}
+	threadNode, err := assignThreads(loadCfg, endpoints)
+	if err != nil {
+		return err
+	}
+
+	// Only the nodes with threads are checked, in endpoint order. The first
+	// thread's node deploys the contract.
+	loaded := distinctNodes(threadNode)
+	sort.Ints(loaded)
+
+	var deployNode *preflightNode
+	nodes := make([]*preflightNode, len(loaded))
+	for i, node := range loaded {
+		nodes[i] = &preflightNode{ep: endpoints[node]}
+		preflightReach(ctx, rootCfg, loadCfg, nodes[i], len(endpoints) > 1)
+		if node == threadNode[0] {
+			deployNode = nodes[i]
+		}
+	}
+
+	preflightChainID(nodes)
+	preflightHeads(ctx, rootCfg, nodes, maxHeadLag)
+
+	if loadCfg.PrivateFor != "" {
+		for _, n := range nodes {
+			preflightTessera(ctx, rootCfg, loadCfg, n)
+		}
+	}
+
+	var deployErr error
+	if loadCfg.DeployKey != "" && rootCfg.ContractAddress == "" && deployNode.ethC != nil {
+		deployErr = preflightDeployKey(ctx, rootCfg, loadCfg, deployNode.ethC)
+	}
+
+	var failed int
+	for _, n := range nodes {
+		if len(n.failed) == 0 {
+			fmt.Printf("ok   %s %s chainid: %v, head: %d, peers: %d\n",
+				n.ep.Name, n.ep.Eth, n.chainID, n.health.BlockNumber, n.health.Peers)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s %s\n", n.ep.Name, n.ep.Eth)
+		for _, reason := range n.failed {
+			fmt.Printf("     %s\n", reason)
+		}
+	}
+	if deployErr != nil {
+		fmt.Printf("FAIL deploy key: %v\n", deployErr)
+	}
+
+	if failed != 0 || deployErr != nil {
+		return fmt.Errorf("preflight failed for %d of %d nodes", failed, len(nodes))
+	}
+	fmt.Printf("preflight ok for %d nodes\n", len(nodes))
+	return nil
+}
+
+// preflightReach connects to the node and probes its chain id, peers and
+// sync status
+func preflightReach(ctx context.Context, rootCfg *root.Config, loadCfg *Config, n *preflightNode, requirePeers bool) {
+
+	var err error
+	n.ethC, err = client.NewClient(n.ep.Eth, "", rootCfg.ClientTimeout, endpointClientOptions(rootCfg, loadCfg, n.ep)...)
+	if err != nil {
+		n.fail("connecting: %v", err)
+		return
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, rootCfg.ClientTimeout)
+	defer cancel()
+
+	if n.chainID, err = n.ethC.ChainID(cctx); err != nil {
+		n.fail("unreachable, eth_chainId: %v", err)
+		n.ethC = nil
+		return
+	}
+
+	n.health = probeNode(cctx, n.ethC, requirePeers)
+	if !n.health.Healthy {
+		n.fail("%s", n.health.Reason)
+	}
+}
+
+// preflightChainID fails nodes whose chain id differs from the first
+// reachable node
+func preflightChainID(nodes []*preflightNode) {
+
+	var first *preflightNode
+	for _, n := range nodes {
+		if n.chainID == nil {
+			continue
+		}
+		if first == nil {
+			first = n
+			continue
+		}
+		if n.chainID.Cmp(first.chainID) != 0 {
+			n.fail("chain id %v differs from %v on %s", n.chainID, first.chainID, first.ep.Name)
+		}
+	}
+}
+
+// preflightHeads fails nodes more than maxHeadLag behind the highest head, and
+// nodes which have a different block at the lowest head of all the nodes.
+func preflightHeads(ctx context.Context, rootCfg *root.Config, nodes []*preflightNode, maxHeadLag uint64) {
+
+	var reachable []*preflightNode
+	var lowest, highest uint64
+	for _, n := range nodes {
+		if n.ethC == nil || !n.health.Reachable {
+			continue
+		}
+		if len(reachable) == 0 || n.health.BlockNumber < lowest {
+			lowest = n.health.BlockNumber
+		}
+		if n.health.BlockNumber > highest {
+			highest = n.health.BlockNumber
+		}
+		reachable = append(reachable, n)
+	}
+
+	var first *preflightNode
+	var firstHash common.Hash
+	for _, n := range reachable {
+
+		if lag := highest - n.health.BlockNumber; lag > maxHeadLag {
+			n.fail("head %d is %d blocks behind the highest head", n.health.BlockNumber, lag)
+		}
+
+		cctx, cancel := context.WithTimeout(ctx, rootCfg.ClientTimeout)
+		header, err := n.ethC.HeaderByNumber(cctx, new(big.Int).SetUint64(lowest))
+		cancel()
+		if err != nil {
+			n.fail("getting block %d: %v", lowest, err)
+			continue
+		}
+		if first == nil {
+			first, firstHash = n, header.Hash()
+			continue
+		}
+		if header.Hash() != firstHash {
+			n.fail("block %d is %s, on %s it is %s",
+				lowest, header.Hash().Hex(), first.ep.Name, firstHash.Hex())
+		}
+	}
+}
+
+// preflightTessera checks the node has a tessera endpoint and that it
+// responds to upcheck
+func preflightTessera(ctx context.Context, rootCfg *root.Config, loadCfg *Config, n *preflightNode) {
+
+	if n.ep.Tessera == "" {
+		n.fail("privatefor is set but there is no tessera endpoint")
+		return
+	}
+
+	hc, err := rootCfg.TransportConfig.NewHostHTTPClient(
+		rootCfg.ClientTimeout, n.ep.TesseraHost, n.ep.TesseraAuth(loadCfg.TesseraAuth()))
+	if err != nil {
+		n.fail("tessera client: %v", err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(n.ep.Tessera, "/")+"/upcheck", nil)
+	if err != nil {
+		n.fail("tessera upcheck: %v", err)
+		return
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		n.fail("tessera %s unreachable: %v", n.ep.Tessera, err)
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		n.fail("tessera %s upcheck: %s %s", n.ep.Tessera, resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+// preflightDeployKey checks the deploy key account can pay for the deploy, and
+// for funding the thread accounts if there is a gas price. The
+// gas limit and price are found as the loader will find them, so
+// --estimate-gas and --suggest-gas-price are respected.
+func preflightDeployKey(ctx context.Context, rootCfg *root.Config, loadCfg *Config, ethC *client.Client) error {
+
+	key, err := crypto.HexToECDSA(loadCfg.DeployKey)
+	if err != nil {
+		return err
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	ctx, cancel := context.WithTimeout(ctx, rootCfg.ClientTimeout)
+	defer cancel()
+
+	balance, err := ethC.BalanceAt(ctx, from, nil)
+	if err != nil {
+		return fmt.Errorf("eth_getBalance %s: %w", from.Hex(), err)
+	}
+
+	gasLimit := loadCfg.DeployGasLimit
+	if loadCfg.EstimateGas {
+		gas, err := ethC.EstimateGas(ctx, ethereum.CallMsg{From: from, Data: common.FromHex(GetSetAddBin)})
+		if err != nil {
+			return fmt.Errorf("eth_estimateGas for deploy: %w", err)
+		}
+		gasLimit = scaleGas(gas, loadCfg.GasMultiplier)
+	}
+	gasPrice := loadCfg.GasPrice
+	if loadCfg.SuggestGasPrice {
+		if gasPrice, err = suggestedGasPrice(ctx, ethC); err != nil {
+			return err
+		}
+	}
+
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), new(big.Int).SetUint64(gasPrice))
+	// The deploy key also funds the thread accounts when there is a gas price
+	cost.Add(cost, fundingCost(loadCfg, gasPrice))
+	if balance.Cmp(cost) < 0 {
+		return fmt.Errorf("%s has balance %v, the deploy and funding may cost up to %v", from.Hex(), balance, cost)
+	}
+	// quorum requires a balance to deploy even when the gas price is zero
+	if balance.Sign() == 0 {
+		return fmt.Errorf("%s has no balance", from.Hex())
+	}
+	fmt.Printf("deploy key %s balance: %v\n", from.Hex(), balance)
+	return nil
+}
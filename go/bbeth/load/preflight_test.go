@@ -0,0 +1,127 @@
+package load
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/robinbryce/benchblock/bbeth/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreflightAssignment(t *testing.T) {
+
+	nodes := []*fakeNode{
+		{chainID: 10, blockNumber: 5, peers: 2},
+		{chainID: 10, blockNumber: 5, peers: 2},
+		// A validator which is down and on a different chain
+		{chainID: 99, down: true},
+	}
+	var urls []string
+	for _, n := range nodes {
+		urls = append(urls, n.serve(t))
+	}
+
+	rootCfg := root.NewConfig()
+	loadCfg := NewConfigLoader()
+	loadCfg.Threads = 4
+	resolver := client.HostResolver{}
+
+	endpointsFile := func(t *testing.T) string {
+		dir := t.TempDir()
+		writeFile(t, dir, "endpoints.yaml", `
+- name: rpc0
+  eth: `+urls[0]+`
+- name: rpc1
+  eth: `+urls[1]+`
+- name: validator
+  eth: `+urls[2]+`
+  validator: true
+`)
+		rootCfg.Endpoints = "endpoints.yaml"
+		return dir
+	}
+
+	dir := endpointsFile(t)
+	// Round robin puts threads on the validator
+	assert.Error(t, preflight(context.Background(), dir, &rootCfg, &loadCfg, 0, resolver))
+
+	loadCfg.Assign = AssignNonValidators
+	assert.NoError(t, preflight(context.Background(), dir, &rootCfg, &loadCfg, 0, resolver))
+
+	loadCfg.Assign = AssignNamed
+	loadCfg.AssignNodes = []string{"rpc1"}
+	assert.NoError(t, preflight(context.Background(), dir, &rootCfg, &loadCfg, 0, resolver))
+	loadCfg.AssignNodes = []string{"validator"}
+	assert.Error(t, preflight(context.Background(), dir, &rootCfg, &loadCfg, 0, resolver))
+
+	loadCfg.Assign = AssignRoundRobin
+	loadCfg.SingleNode = true
+	assert.NoError(t, preflight(context.Background(), dir, &rootCfg, &loadCfg, 0, resolver))
+}
+
+func TestPreflightDeployKey(t *testing.T) {
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	node := &fakeNode{balance: 1000000, gasPrice: 20, deployCost: 100000}
+	ethC := node.dial(t)
+
+	rootCfg := root.NewConfig()
+	loadCfg := NewConfigLoader()
+	loadCfg.DeployKey = hex.EncodeToString(crypto.FromECDSA(key))
+	loadCfg.DeployGasLimit = 600000
+	loadCfg.GasMultiplier = 1.5
+	// One account making one transaction of 1 gas, so funding it costs
+	// 21001 * price
+	loadCfg.Threads, loadCfg.ThreadAccounts, loadCfg.NumTransactions = 1, 1, 1
+	loadCfg.GasLimit = 1
+
+	for _, tc := range []struct {
+		name     string
+		price    uint64
+		suggest  bool
+		estimate bool
+		ok       bool
+	}{
+		// Free gas still needs a balance, which there is
+		{name: "zero price", ok: true},
+		// 600000 * 2 > 1000000
+		{name: "configured", price: 2},
+		{name: "configured, within balance", price: 1, ok: true},
+		// The suggested 20 costs 12000000 at the deploy gas limit
+		{name: "suggested", suggest: true},
+		// 150000 * 20 > 1000000
+		{name: "suggested and estimated", suggest: true, estimate: true},
+		// (150000 + 21001) * 5 <= 1000000, where the configured limit would
+		// not be
+		{name: "estimated", price: 5, estimate: true, ok: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			loadCfg.GasPrice = tc.price
+			loadCfg.SuggestGasPrice = tc.suggest
+			loadCfg.EstimateGas = tc.estimate
+			err := preflightDeployKey(context.Background(), &rootCfg, &loadCfg, ethC)
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+
+	// The deploy fits the balance but funding four accounts for two
+	// transactions each does not: 600000 + 4 * (21000 + 2 * 60000) > 1000000
+	loadCfg.GasPrice, loadCfg.SuggestGasPrice, loadCfg.EstimateGas = 1, false, false
+	loadCfg.Threads, loadCfg.ThreadAccounts, loadCfg.NumTransactions = 2, 2, 8
+	loadCfg.GasLimit = 60000
+	assert.Error(t, preflightDeployKey(context.Background(), &rootCfg, &loadCfg, ethC))
+
+	node.set(func(n *fakeNode) { n.balance = 0 })
+	loadCfg.GasPrice, loadCfg.SuggestGasPrice, loadCfg.EstimateGas = 0, false, false
+	assert.Error(t, preflightDeployKey(context.Background(), &rootCfg, &loadCfg, ethC))
+}
@@ -0,0 +1,143 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/robinbryce/benchblock/bbeth/client"
+)
+
+// SignedTx is a transaction signed ahead of the timed phase. Raw is the hex
+// encoded rlp ready for eth_sendRawTransaction.
+type SignedTx struct {
+	Tx  *types.Transaction
+	Raw string
+}
+
+// presign builds and signs every transaction each thread will issue. The
+// accounts are fresh for each run so the nonces are known up front. The
+// result is indexed by thread then by batch * ThreadAccounts + account, which
+// is the order the adder issues them in. Nonces in the account sets are
+// advanced past the signed transactions.
+func (a *Loader) presign() error {
+
+	if a.loadCfg.PrivateFor != "" {
+		return fmt.Errorf("--presign does not support private transactions")
+	}
+	if !a.loadCfg.MangeNonce {
+		return fmt.Errorf("--presign requires the nonces to be managed by the loader")
+	}
+
+	start := time.Now()
+
+	input, err := a.abi.Pack("add", big.NewInt(2))
+	if err != nil {
+		return err
+	}
+
+	numBatches := a.loadCfg.NumTransactions / (a.loadCfg.Threads * a.loadCfg.ThreadAccounts)
+
+	a.signed = make([][]SignedTx, a.loadCfg.Threads)
+	for ias := 0; ias < a.loadCfg.Threads; ias++ {
+
+		a.signed[ias] = make([]SignedTx, numBatches*a.loadCfg.ThreadAccounts)
+
+		for r := 0; r < numBatches; r++ {
+			for i := 0; i < a.loadCfg.ThreadAccounts; i++ {
+
+				auth := a.accounts[ias].Auth[i]
+
+				tx := types.NewTransaction(
+					auth.Nonce.Uint64(), a.address, new(big.Int), auth.GasLimit, auth.GasPrice, input)
+				tx, err = auth.Signer(types.HomesteadSigner{}, auth.From, tx)
+				if err != nil {
+					return err
+				}
+				raw, err := rlp.EncodeToBytes(tx)
+				if err != nil {
+					return err
+				}
+
+				a.signed[ias][r*a.loadCfg.ThreadAccounts+i] = SignedTx{Tx: tx, Raw: hexutil.Encode(raw)}
+				a.accounts[ias].IncNonce(i)
+			}
+		}
+	}
+	a.log.Info("presigned transactions", "count", numBatches*a.loadCfg.ThreadAccounts*a.loadCfg.Threads, "elapsed", time.Since(start))
+	return nil
+}
+
+// batchAdder is the adder for BatchSize > 1. It issues the presigned
+// transactions for the thread using json-rpc batch requests of BatchSize.
+// The limiter is respected per transaction, so a batch is sent once enough
+// ticks for all its transactions have elapsed.
+func (lo *Loader) batchAdder(wg *sync.WaitGroup, banner string, ias int) {
+
+	defer wg.Done()
+
+	l := lo.threadLogger(ias)
+
+	signed := lo.signed[ias]
+	raws := make([]string, 0, lo.loadCfg.BatchSize)
+
+	for start := 0; start < len(signed); start += lo.loadCfg.BatchSize {
+
+		end := start + lo.loadCfg.BatchSize
+		if end > len(signed) {
+			end = len(signed)
+		}
+
+		if !lo.pb.IsEnabled() {
+			l.Info("rpc batch", "batch", start/lo.loadCfg.BatchSize)
+		}
+
+		raws = raws[:0]
+		for _, s := range signed[start:end] {
+			if lo.limiter != nil {
+				<-lo.limiter.C
+			}
+			raws = append(raws, s.Raw)
+		}
+
+		lo.failover(ias)
+
+		ctx, cancel := context.WithTimeout(context.Background(), lo.rootCfg.ClientTimeout)
+		errs, err := client.SendRawTransactions(ctx, lo.ethC[ias].RPC, raws)
+		cancel()
+		if err != nil {
+			l.Error("batch send", "err", err)
+			for range raws {
+				lo.metrics.Errored(err)
+				lo.results.Add(lo.threadNodeName(ias), client.SendResult(err))
+			}
+			continue
+		}
+
+		for i, err := range errs {
+			if err != nil {
+				l.Error("send", "tx", signed[start+i].Tx.Hash().Hex(), "err", err)
+				lo.metrics.Errored(err)
+				lo.results.Add(lo.threadNodeName(ias), client.SendResult(err))
+				continue
+			}
+			lo.pb.IssuedIncrement()
+			lo.metrics.Issued(1)
+			if lo.receipts != nil {
+				lo.receipts.Submit(lo.ethC[ias].Client, lo.threadNodeName(ias), signed[start+i].Tx)
+			} else {
+				lo.results.Add(lo.threadNodeName(ias), client.TxSent)
+			}
+			// Presigned transactions are all public, the collector only needs
+			// them for the inclusion metric
+			if lo.collector != nil && lo.metrics != nil {
+				lo.collector.TxSent(signed[start+i].Tx.Hash(), "public")
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package load
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/robinbryce/benchblock/bbeth/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAccountSet makes an account set of n fresh keys, with managed nonces
+// starting at 0, without needing a node
+func testAccountSet(t *testing.T, chainID *big.Int, n int) client.AccountSet {
+
+	a := client.AccountSet{
+		Wallets: make([]common.Address, n),
+		Keys:    make([]*ecdsa.PrivateKey, n),
+		Auth:    make([]*bind.TransactOpts, n),
+	}
+	for i := 0; i < n; i++ {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		a.Keys[i] = key
+		a.Wallets[i] = crypto.PubkeyToAddress(key.PublicKey)
+		a.Auth[i] = client.NewKeyedTransactor(key, chainID)
+		a.Auth[i].GasLimit = 60000
+		a.Auth[i].GasPrice = new(big.Int)
+		a.Auth[i].Nonce = new(big.Int)
+	}
+	return a
+}
+
+// testLoader makes a loader, with threads account sets of accounts each,
+// which can presign
+func testLoader(t *testing.T, threads, accounts, transactions int) *Loader {
+
+	cfg := NewConfigLoader()
+	cfg.Threads = threads
+	cfg.ThreadAccounts = accounts
+	cfg.NumTransactions = transactions
+
+	parsed, err := abi.JSON(strings.NewReader(GetSetAddABI))
+	require.NoError(t, err)
+
+	rootCfg := root.NewConfig()
+
+	chainID := big.NewInt(1337)
+	lo := &Loader{
+		rootCfg: &rootCfg, loadCfg: &cfg, log: log.Root(), abi: parsed, chainID: chainID,
+		address: common.HexToAddress("0x1000"),
+	}
+	for i := 0; i < threads; i++ {
+		lo.accounts = append(lo.accounts, testAccountSet(t, chainID, accounts))
+	}
+	return lo
+}
+
+func TestPresign(t *testing.T) {
+
+	lo := testLoader(t, 2, 3, 12)
+	require.NoError(t, lo.presign())
+
+	signer := types.NewEIP155Signer(lo.chainID)
+	require.Len(t, lo.signed, 2)
+	for ias, signed := range lo.signed {
+		// Two batches of three accounts
+		require.Len(t, signed, 6)
+		for j, s := range signed {
+			batch, account := j/3, j%3
+
+			from, err := types.Sender(signer, s.Tx)
+			require.NoError(t, err)
+			assert.Equal(t, lo.accounts[ias].Wallets[account], from)
+			assert.Equal(t, uint64(batch), s.Tx.Nonce())
+			assert.Equal(t, lo.address, *s.Tx.To())
+
+			// Raw is the signed transaction, ready to send
+			var decoded types.Transaction
+			require.NoError(t, rlp.DecodeBytes(hexutil.MustDecode(s.Raw), &decoded))
+			assert.Equal(t, s.Tx.Hash(), decoded.Hash())
+		}
+		// The account nonces are advanced past the signed transactions
+		for _, auth := range lo.accounts[ias].Auth {
+			assert.Equal(t, uint64(2), auth.Nonce.Uint64())
+		}
+	}
+}
+
+func TestPresignRequirements(t *testing.T) {
+
+	lo := testLoader(t, 1, 1, 1)
+	lo.loadCfg.PrivateFor = "A"
+	assert.Error(t, lo.presign())
+
+	lo = testLoader(t, 1, 1, 1)
+	lo.loadCfg.MangeNonce = false
+	assert.Error(t, lo.presign())
+}
+
+func TestBatchAdder(t *testing.T) {
+
+	lo := testLoader(t, 1, 2, 10)
+	lo.loadCfg.BatchSize = 4
+	require.NoError(t, lo.presign())
+	signed := lo.signed[0]
+	require.Len(t, signed, 10)
+
+	node := &fakeNode{reject: map[common.Hash]error{signed[5].Tx.Hash(): errNonceTooLow}}
+	lo.ethC = []*client.Client{node.dial(t)}
+	lo.ethCUrl = []string{"inproc"}
+	lo.endpoints = []client.Endpoint{{Name: "node-0"}}
+	lo.threadNode = []int{0}
+	lo.pb = client.NewTransactionProgress(lo.loadCfg.NumTransactions)
+	lo.results = NewResultReport()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	lo.batchAdder(&wg, "", 0)
+	wg.Wait()
+
+	// Three batches, 4, 4 and 2, all sent in order except the rejected one
+	var expect []common.Hash
+	for i, s := range signed {
+		if i != 5 {
+			expect = append(expect, s.Tx.Hash())
+		}
+	}
+	assert.Equal(t, expect, node.sent)
+	assert.Equal(t, 9, lo.results.Count("node-0", client.TxSent))
+	assert.Equal(t, 1, lo.results.Count("node-0", client.TxNonceError))
+}
@@ -0,0 +1,337 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/private/engine"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/robinbryce/benchblock/bbeth/collect"
+)
+
+// privateContract is an instance of the contract which is private to the
+// key that deployed it and the parties in one of the PrivateFor sets. Private
+// state is only shared by the parties, so each key sending private
+// transactions needs its own instance for each set.
+type privateContract struct {
+	address     common.Address
+	node        int
+	privateFrom string
+	set         int
+}
+
+// privateBinding is the ContractTransactor for threads sending private
+// transactions. The contract instance is chosen by the PrivateFor set on the
+// TransactOpts. Unlike bind.BoundContract it can set the privacy flag.
+type privateBinding struct {
+	ethC        *client.Client
+	abi         abi.ABI
+	privateFrom string
+	flag        engine.PrivacyFlagType
+	// contracts is indexed by the key of the PrivateFor set, see privateForKey
+	contracts map[string]*privateContract
+}
+
+func privateForKey(privateFor []string) string {
+	return strings.Join(privateFor, ":")
+}
+
+func (b *privateBinding) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+
+	contract, ok := b.contracts[privateForKey(opts.PrivateFor)]
+	if !ok {
+		return nil, fmt.Errorf("no private contract for privatefor %v", opts.PrivateFor)
+	}
+
+	input, err := b.abi.Pack(method, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var nonce uint64
+	if opts.Nonce != nil {
+		nonce = opts.Nonce.Uint64()
+	} else if nonce, err = b.ethC.PendingNonceAt(ctx, opts.From); err != nil {
+		return nil, err
+	}
+
+	tx := types.NewTransaction(nonce, contract.address, new(big.Int), opts.GasLimit, opts.GasPrice, input)
+	if tx, err = b.ethC.SignPrivate(opts, tx, b.privateFrom); err != nil {
+		return nil, err
+	}
+
+	err = b.ethC.SendRawPrivateTransaction(ctx, tx, client.PrivateTxArgs{
+		PrivateFrom: b.privateFrom, PrivateFor: opts.PrivateFor, PrivacyFlag: b.flag})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// mixedBinding sends transactions with no PrivateFor to the public contract
+// and the rest to the private instances. It is used when PrivateRatio
+// interleaves public and private transactions.
+type mixedBinding struct {
+	public  ContractTransactor
+	private ContractTransactor
+}
+
+func (b *mixedBinding) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	if len(opts.PrivateFor) == 0 {
+		return b.public.Transact(opts, method, params...)
+	}
+	return b.private.Transact(opts, method, params...)
+}
+
+// isMixed is true if public and private transactions are interleaved
+func (a *Loader) isMixed() bool {
+	return len(a.privateFor) != 0 && a.loadCfg.PrivateRatio > 0 && a.loadCfg.PrivateRatio < 1
+}
+
+// isPrivateTx returns whether the n'th transaction of a thread is private. In
+// mixed mode the private transactions are spread evenly, at PrivateRatio.
+func (a *Loader) isPrivateTx(n int) bool {
+	if !a.isMixed() {
+		return len(a.privateFor) != 0
+	}
+	ratio := a.loadCfg.PrivateRatio
+	return int(float64(n+1)*ratio) != int(float64(n)*ratio)
+}
+
+// privateForTx returns the PrivateFor set for the n'th transaction of a
+// thread, or nil if the transaction is public. private is the number of
+// private transactions the thread has already sent. Only the private transactions advance the rotation
+// through the sets, so every set is used whatever the PrivateRatio.
+func (a *Loader) privateForTx(n, private int) []string {
+	if len(a.privateFor) == 0 || !a.isPrivateTx(n) {
+		return nil
+	}
+	return a.privateFor[private%len(a.privateFor)]
+}
+
+// newContractBinding returns the binding thread transactions are issued with
+// for a connection to the node. Private transactions are sent from the tessera
+// key privateFrom.
+func (a *Loader) newContractBinding(ethC *client.Client, node int, privateFrom string) ContractTransactor {
+
+	public := bind.NewBoundContract(a.address, a.abi, ethC, ethC, ethC)
+	if len(a.privateFor) == 0 {
+		return public
+	}
+
+	b := &privateBinding{
+		ethC:        ethC,
+		abi:         a.abi,
+		privateFrom: privateFrom,
+		flag:        a.privacyFlag,
+		contracts:   map[string]*privateContract{},
+	}
+	for _, c := range a.private {
+		if c.node == node && c.privateFrom == privateFrom {
+			b.contracts[privateForKey(a.privateFor[c.set])] = c
+		}
+	}
+	if a.isMixed() {
+		return &mixedBinding{public: public, private: b}
+	}
+	return b
+}
+
+// privateSender is a node and the tessera key private transactions are sent
+// from on it
+type privateSender struct {
+	node        int
+	privateFrom string
+}
+
+// privateSenders returns the distinct keys threads send from, on each node,
+// including the node keys used after failover
+func (a *Loader) privateSenders() []privateSender {
+
+	seen := map[privateSender]bool{}
+	var senders []privateSender
+	add := func(s privateSender) {
+		if !seen[s] {
+			seen[s] = true
+			senders = append(senders, s)
+		}
+	}
+	for i, node := range a.threadNode {
+		add(privateSender{node: node, privateFrom: a.threadPrivateFrom[i]})
+	}
+	if a.loadCfg.Failover {
+		for _, node := range a.failoverNodes {
+			add(privateSender{node: node, privateFrom: a.endpoints[node].PrivateFrom})
+		}
+	}
+	return senders
+}
+
+// deployPrivateContracts deploys a private instance of the contract from
+// each key threads may send from (including after failover) for each
+// PrivateFor set.
+func (a *Loader) deployPrivateContracts(ctx context.Context) error {
+
+	deployKey, err := a.deployKey()
+	if err != nil {
+		return err
+	}
+	deployAuth := client.NewKeyedTransactor(deployKey, a.chainID)
+	code := common.FromHex(GetSetAddBin)
+
+	// The deploys are sequential, so a local nonce is safe and avoids
+	// depending on how quickly each node sees the previous deploy.
+	var nonce uint64
+	if nonce, err = a.ethC[0].PendingNonceAt(ctx, deployAuth.From); err != nil {
+		return err
+	}
+
+	for _, sender := range a.privateSenders() {
+
+		node, privateFrom := sender.node, sender.privateFrom
+
+		// Any thread connection to the node will do
+		var ethC *client.Client
+		for i := range a.threadNode {
+			if a.threadNode[i] == node {
+				ethC = a.ethC[i]
+				break
+			}
+		}
+
+		for set, privateFor := range a.privateFor {
+
+			tx := types.NewContractCreation(
+				nonce, new(big.Int), a.loadCfg.DeployGasLimit, new(big.Int).SetUint64(a.loadCfg.GasPrice), code)
+			if tx, err = ethC.SignPrivate(deployAuth, tx, privateFrom); err != nil {
+				return err
+			}
+
+			sctx, cancel := context.WithTimeout(ctx, a.rootCfg.ClientTimeout)
+			err = ethC.SendRawPrivateTransaction(sctx, tx, client.PrivateTxArgs{
+				PrivateFrom: privateFrom, PrivateFor: privateFor, PrivacyFlag: a.privacyFlag})
+			cancel()
+			if err != nil {
+				return fmt.Errorf("deploying private contract from %s: %w", a.endpoints[node].Name, err)
+			}
+			nonce++
+
+			receipt, err := client.WaitReceipt(ethC.Client, tx, a.rootCfg.Retries, a.loadCfg.ExpectedLatency)
+			if err != nil || receipt.Status != 1 {
+				return fmt.Errorf("failed to deploy private contract from %s", a.endpoints[node].Name)
+			}
+
+			a.private = append(a.private, &privateContract{
+				address: receipt.ContractAddress, node: node, privateFrom: privateFrom, set: set})
+			a.log.Info("deployed private contract", "contract", receipt.ContractAddress.Hex(),
+				"node", a.endpoints[node].Name, "privatefrom", privateFrom, "privatefor", privateForKey(privateFor))
+		}
+	}
+	return nil
+}
+
+// privateAddresses returns the addresses of the private contract instances
+func (a *Loader) privateAddresses() []common.Address {
+	addresses := make([]common.Address, len(a.private))
+	for i, c := range a.private {
+		addresses[i] = c.address
+	}
+	return addresses
+}
+
+// privateParties says, for each node, whether it is party to the private
+// contract. The deploying node is. Other nodes are if one of their keys is
+// in the contracts PrivateFor set, and unknown if they have no known keys.
+func (a *Loader) privateParties(c *privateContract) []collect.Party {
+
+	parties := make([]collect.Party, len(a.endpoints))
+	for n := range a.endpoints {
+		switch {
+		case n == c.node:
+			parties[n] = collect.PartyYes
+		case len(a.nodeKeys[n]) == 0:
+			parties[n] = collect.PartyUnknown
+		default:
+			parties[n] = collect.PartyNo
+			for _, key := range a.nodeKeys[n] {
+				if containsKey(a.privateFor[c.set], key) {
+					parties[n] = collect.PartyYes
+				}
+			}
+		}
+	}
+	return parties
+}
+
+// resolvePrivateFrom returns the key each thread sends private transactions
+// from, and the keys known to be held by each nodes tessera. keys is the ','
+// separated ThreadPrivateFrom list, threads without an entry use the key of
+// the node they are assigned to.
+func resolvePrivateFrom(keys string, endpoints []client.Endpoint, threadNode []int) ([]string, [][]string, error) {
+
+	var listed []string
+	if keys != "" {
+		listed = strings.Split(keys, ",")
+	}
+	if len(listed) > len(threadNode) {
+		return nil, nil, fmt.Errorf(
+			"--thread-privatefrom has %d keys for %d threads", len(listed), len(threadNode))
+	}
+
+	nodeKeys := make([][]string, len(endpoints))
+	for n, ep := range endpoints {
+		if ep.PrivateFrom != "" {
+			nodeKeys[n] = []string{ep.PrivateFrom}
+		}
+	}
+
+	threadKeys := make([]string, len(threadNode))
+	for i, node := range threadNode {
+		if i < len(listed) {
+			threadKeys[i] = strings.TrimSpace(listed[i])
+		}
+		if threadKeys[i] == "" {
+			threadKeys[i] = endpoints[node].PrivateFrom
+			continue
+		}
+		if !containsKey(nodeKeys[node], threadKeys[i]) {
+			nodeKeys[node] = append(nodeKeys[node], threadKeys[i])
+		}
+	}
+	return threadKeys, nodeKeys, nil
+}
+
+// verifyPrivate has the collector check, on every node, the private state
+// of each private contract. The parties are those known from the tessera
+// keys, see privateParties.
+func (a *Loader) verifyPrivate() error {
+
+	names, nodes, err := a.nodeClients()
+	if err != nil {
+		return err
+	}
+	for _, c := range a.private {
+		a.collector.SetPrivateParties(c.address, a.privateParties(c))
+	}
+	return a.collector.VerifyPrivate(names, nodes)
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
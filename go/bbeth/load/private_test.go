@@ -0,0 +1,158 @@
+package load
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/robinbryce/benchblock/bbeth/collect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePrivateFrom(t *testing.T) {
+
+	endpoints := []client.Endpoint{{PrivateFrom: "A"}, {PrivateFrom: "B"}, {}}
+	threadNode := []int{0, 1, 0, 2}
+
+	// Without a list every thread uses its nodes key
+	keys, nodeKeys, err := resolvePrivateFrom("", endpoints, threadNode)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A", "B", "A", ""}, keys)
+	assert.Equal(t, [][]string{{"A"}, {"B"}, nil}, nodeKeys)
+
+	// Listed keys are added to the keys of the threads node, blank and
+	// missing entries fall back to the node key
+	keys, nodeKeys, err = resolvePrivateFrom("A2, ,A", endpoints, threadNode)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A2", "B", "A", ""}, keys)
+	assert.Equal(t, [][]string{{"A", "A2"}, {"B"}, nil}, nodeKeys)
+
+	_, _, err = resolvePrivateFrom("A,B,A,C,D", endpoints, threadNode)
+	assert.Error(t, err)
+}
+
+// testPrivateLoader has two threads on node 0, sending from different keys,
+// and one on node 1. Node 2 has no threads and no known key.
+func testPrivateLoader(t *testing.T) *Loader {
+
+	lo := testLoader(t, 3, 1, 3)
+	lo.endpoints = []client.Endpoint{{Name: "n0", PrivateFrom: "A"}, {Name: "n1", PrivateFrom: "B"}, {Name: "n2"}}
+	lo.threadNode = []int{0, 0, 1}
+	var err error
+	lo.threadPrivateFrom, lo.nodeKeys, err = resolvePrivateFrom("A,A2", lo.endpoints, lo.threadNode)
+	require.NoError(t, err)
+	lo.failoverNodes = distinctNodes(lo.threadNode)
+	lo.privateFor = client.ParsePrivateFor("B,A2")
+	return lo
+}
+
+func TestPrivateSenders(t *testing.T) {
+
+	lo := testPrivateLoader(t)
+	assert.Equal(t, []privateSender{{0, "A"}, {0, "A2"}, {1, "B"}}, lo.privateSenders())
+
+	// Failover only moves threads to the node key
+	lo.threadNode = []int{0, 1, 1}
+	lo.threadPrivateFrom = []string{"A", "A2", "B"}
+	lo.loadCfg.Failover = true
+	assert.Equal(t, []privateSender{{0, "A"}, {1, "A2"}, {1, "B"}}, lo.privateSenders())
+}
+
+func TestPrivateParties(t *testing.T) {
+
+	lo := testPrivateLoader(t)
+
+	// Node 0 deployed, for B
+	c := &privateContract{node: 0, privateFrom: "A", set: 0}
+	assert.Equal(t, []collect.Party{collect.PartyYes, collect.PartyYes, collect.PartyUnknown}, lo.privateParties(c))
+
+	// Node 1 deployed, for a thread key of node 0
+	c = &privateContract{node: 1, privateFrom: "B", set: 1}
+	assert.Equal(t, []collect.Party{collect.PartyYes, collect.PartyYes, collect.PartyUnknown}, lo.privateParties(c))
+
+	// Node 0 deployed, for its own thread key. Node 1 is not a party.
+	c = &privateContract{node: 0, privateFrom: "A", set: 1}
+	assert.Equal(t, []collect.Party{collect.PartyYes, collect.PartyNo, collect.PartyUnknown}, lo.privateParties(c))
+}
+
+func TestPrivateBindings(t *testing.T) {
+
+	lo := testPrivateLoader(t)
+	for i, s := range lo.privateSenders() {
+		for set := range lo.privateFor {
+			lo.private = append(lo.private, &privateContract{
+				address: common.BigToAddress(big.NewInt(int64(0x100*(i+1) + set))), node: s.node, privateFrom: s.privateFrom, set: set})
+		}
+	}
+
+	// Each thread binding has the instances deployed from its own key
+	for i := range lo.threadNode {
+		b, ok := lo.newContractBinding(nil, lo.threadNode[i], lo.threadPrivateFrom[i]).(*privateBinding)
+		require.True(t, ok)
+		assert.Equal(t, lo.threadPrivateFrom[i], b.privateFrom)
+		require.Len(t, b.contracts, len(lo.privateFor))
+		for _, c := range b.contracts {
+			assert.Equal(t, lo.threadNode[i], c.node)
+			assert.Equal(t, lo.threadPrivateFrom[i], c.privateFrom)
+		}
+	}
+
+}
+
+func TestIsPrivateTx(t *testing.T) {
+
+	lo := testLoader(t, 1, 1, 8)
+	lo.privateFor = client.ParsePrivateFor("A,B")
+
+	private := func(ratio float64) []int {
+		lo.loadCfg.PrivateRatio = ratio
+		var ns []int
+		for n := 0; n < 8; n++ {
+			if lo.isPrivateTx(n) {
+				ns = append(ns, n)
+			}
+		}
+		return ns
+	}
+	assert.Equal(t, []int{3, 7}, private(0.25))
+	assert.Equal(t, []int{1, 3, 5, 7}, private(0.5))
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, private(1.0))
+	// Unset is all private
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, private(0))
+
+	// Without PrivateFor everything is public
+	lo.privateFor = nil
+	lo.loadCfg.PrivateRatio = 0.5
+	assert.False(t, lo.isMixed())
+	assert.Empty(t, private(0.5))
+}
+
+func TestPrivateForRotation(t *testing.T) {
+
+	lo := testLoader(t, 1, 1, 8)
+	lo.privateFor = client.ParsePrivateFor("A,B")
+
+	// The sets used by the private transactions of a thread, as the adder
+	// picks them
+	sets := func(ratio float64) []string {
+		lo.loadCfg.PrivateRatio = ratio
+		var used []string
+		var private int
+		for n := 0; n < 8; n++ {
+			privateFor := lo.privateForTx(n, private)
+			if privateFor == nil {
+				continue
+			}
+			private++
+			used = append(used, privateForKey(privateFor))
+		}
+		return used
+	}
+	// Every set is used, even when the private transactions all fall on odd
+	// (or even) n
+	assert.Equal(t, []string{"A", "B"}, sets(0.25))
+	assert.Equal(t, []string{"A", "B", "A", "B"}, sets(0.5))
+	assert.Equal(t, []string{"A", "B", "A", "B", "A", "B", "A", "B"}, sets(1.0))
+}
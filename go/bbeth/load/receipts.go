@@ -0,0 +1,169 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/robinbryce/benchblock/bbeth/client"
+)
+
+// pendingReceipt is a sent transaction waiting for its receipt
+type pendingReceipt struct {
+	tx *types.Transaction
+	// The connection the transaction was sent on. Private receipts are only
+	// available from the parties.
+	ethC *ethclient.Client
+	node string
+	sent time.Time
+}
+
+// receiptPoller checks the receipts for the transactions submitted to it using
+// a pool of goroutines, so that checking does not hold up sending. The
+// outcome of each transaction is counted in the loaders ResultReport and, if
+// there is a collector, recorded with its latency in the results db.
+type receiptPoller struct {
+	lo      *Loader
+	log     log.Logger
+	pending chan pendingReceipt
+	wg      sync.WaitGroup
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	// queued is how long each mined transaction waited for a free poller.
+	// It is part of its latency.
+	queued []time.Duration
+}
+
+// newReceiptPoller starts n pollers. The queue holds every transaction for
+// the run so that sending never waits on it.
+func newReceiptPoller(lo *Loader, n int) *receiptPoller {
+
+	p := &receiptPoller{
+		lo:      lo,
+		log:     lo.log.New("component", "receipts"),
+		pending: make(chan pendingReceipt, lo.loadCfg.NumTransactions),
+	}
+	p.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go p.run()
+	}
+	return p
+}
+
+// Submit queues tx, just sent to node on ethC, for its receipt to be checked
+func (p *receiptPoller) Submit(ethC *ethclient.Client, node string, tx *types.Transaction) {
+	p.pending <- pendingReceipt{tx: tx, ethC: ethC, node: node, sent: time.Now()}
+}
+
+// Close waits for the receipts of all submitted transactions. Submit must not
+// be called after Close.
+func (p *receiptPoller) Close() {
+	close(p.pending)
+	p.wg.Wait()
+}
+
+func (p *receiptPoller) run() {
+
+	defer p.wg.Done()
+
+	for pr := range p.pending {
+		// Under load transactions wait in the queue. A receipt found on the
+		// first poll after a long wait says little about when it was mined,
+		// so the wait is recorded too.
+		queued := time.Since(pr.sent)
+		r, err := client.WaitReceipt(pr.ethC, pr.tx, p.lo.rootCfg.Retries, p.lo.loadCfg.ExpectedLatency)
+		latency := time.Since(pr.sent)
+		result := client.ReceiptResult(r, err)
+		p.lo.results.Add(pr.node, result)
+		p.record(pr, r, result, latency, queued)
+
+		switch result {
+		case client.TxReverted:
+			p.reverted(pr, r)
+		case client.TxMined:
+			p.log.Debug("receipt", "tx", pr.tx.Hash().Hex(), "node", pr.node, "latency", latency, "queued", queued)
+			p.mu.Lock()
+			p.latencies = append(p.latencies, latency)
+			p.queued = append(p.queued, queued)
+			p.mu.Unlock()
+		default:
+			p.log.Error("no valid receipt found", "tx", pr.tx.Hash().Hex(), "node", pr.node, "result", result, "err", err)
+		}
+		// Reverted transactions use gas too
+		p.lo.gasReport.Add("add", pr.tx, r)
+	}
+}
+
+// record saves the outcome and latency of the transaction in the results db
+func (p *receiptPoller) record(
+	pr pendingReceipt, r *types.Receipt, result client.TxResult, latency, queued time.Duration) {
+
+	if p.lo.collector == nil {
+		return
+	}
+	var blockNumber int64
+	if r != nil && r.BlockNumber != nil {
+		blockNumber = r.BlockNumber.Int64()
+	}
+	err := p.lo.collector.RecordReceipt(
+		pr.tx.Hash(), pr.node, result.String(), pr.sent, blockNumber, latency, queued)
+	if err != nil {
+		p.log.Error("recording receipt", "tx", pr.tx.Hash().Hex(), "err", err)
+	}
+}
+
+// reverted finds out why the transaction reverted. The reason is counted in
+// the ResultReport and, if there is a collector, recorded in the results db.
+func (p *receiptPoller) reverted(pr pendingReceipt, r *types.Receipt) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.lo.rootCfg.ClientTimeout)
+	reason, err := client.RevertReason(ctx, pr.ethC, pr.tx, r)
+	cancel()
+	if err != nil {
+		p.log.Warn("revert reason not found", "tx", pr.tx.Hash().Hex(), "node", pr.node, "err", err)
+		reason = "unknown"
+	} else if reason == "" {
+		reason = "replay did not revert"
+	}
+	p.log.Error("transaction reverted", "tx", pr.tx.Hash().Hex(), "node", pr.node,
+		"block", r.BlockNumber, "reason", reason)
+	p.lo.results.AddRevert(reason)
+
+	if p.lo.collector == nil {
+		return
+	}
+	if err = p.lo.collector.RecordRevert(pr.tx.Hash(), pr.node, r.BlockNumber.Int64(), reason); err != nil {
+		p.log.Error("recording revert", "err", err)
+	}
+}
+
+// Print summarises the time from sending each mined transaction to its
+// receipt being found, and the part of that spent waiting for a poller. The
+// polling backoff and the queue mean the latency is an upper bound.
+func (p *receiptPoller) Print() {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.latencies) == 0 {
+		return
+	}
+	latency, queued := percentiles(p.latencies), percentiles(p.queued)
+	fmt.Printf("receipts %d, latency p50 %v, p95 %v, max %v (queued p50 %v, p95 %v, max %v)\n",
+		len(p.latencies), latency(0.5), latency(0.95), latency(1), queued(0.5), queued(0.95), queued(1))
+}
+
+// percentiles sorts d and returns a function for its quantiles, rounded to
+// the millisecond
+func percentiles(d []time.Duration) func(q float64) time.Duration {
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	return func(q float64) time.Duration {
+		return d[int(q*float64(len(d)-1))].Round(time.Millisecond)
+	}
+}
@@ -0,0 +1,96 @@
+package load
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReceipt is a receipt, for tx, which the fake node can encode
+func testReceipt(tx *types.Transaction, status uint64, block int64) *types.Receipt {
+	return &types.Receipt{
+		Status: status, TxHash: tx.Hash(), GasUsed: 30000, CumulativeGasUsed: 30000,
+		Logs: []*types.Log{}, BlockNumber: big.NewInt(block)}
+}
+
+func TestReceiptPoller(t *testing.T) {
+
+	lo := testLoader(t, 1, 4, 4)
+	lo.results = NewResultReport()
+	lo.gasReport = NewGasReport()
+	lo.rootCfg.Retries = 1
+
+	auth := lo.accounts[0].Auth[0]
+	txs := make([]*types.Transaction, 4)
+	for i := range txs {
+		tx, err := auth.Signer(types.NewEIP155Signer(lo.chainID), auth.From,
+			types.NewTransaction(uint64(i), lo.address, new(big.Int), 60000, new(big.Int), nil))
+		require.NoError(t, err)
+		txs[i] = tx
+	}
+
+	// Two mined, one reverted and one never mined
+	node := &fakeNode{
+		receipts: map[common.Hash]*types.Receipt{
+			txs[0].Hash(): testReceipt(txs[0], types.ReceiptStatusSuccessful, 5),
+			txs[1].Hash(): testReceipt(txs[1], types.ReceiptStatusFailed, 5),
+			txs[2].Hash(): testReceipt(txs[2], types.ReceiptStatusSuccessful, 6),
+		},
+		callErr: errors.New("too low"),
+	}
+	ethC := node.dial(t)
+
+	p := newReceiptPoller(lo, 2)
+	for i, tx := range txs {
+		p.Submit(ethC.Client, []string{"node-0", "node-1"}[i%2], tx)
+	}
+	// Close waits for every submitted receipt to be checked
+	p.Close()
+
+	assert.Equal(t, 2, lo.results.Count("", client.TxMined))
+	assert.Equal(t, 2, lo.results.Count("node-0", client.TxMined))
+	assert.Equal(t, 1, lo.results.Count("node-1", client.TxReverted))
+	assert.Equal(t, 1, lo.results.Count("node-1", client.TxNotFound))
+	assert.Equal(t, map[string]int{"too low": 1}, lo.results.reverts)
+	// The revert is replayed on the state before its block
+	assert.Equal(t, []int64{4}, node.calledAt)
+
+	// Latencies are only for the mined transactions, reverted ones use gas
+	assert.Len(t, p.latencies, 2)
+	assert.Len(t, p.queued, 2)
+	assert.Equal(t, uint64(3), lo.gasReport.kinds["add"].count)
+}
+
+func TestReceiptPollerQueued(t *testing.T) {
+
+	lo := testLoader(t, 1, 1, 1)
+	lo.results = NewResultReport()
+	lo.gasReport = NewGasReport()
+	lo.rootCfg.Retries = 1
+
+	auth := lo.accounts[0].Auth[0]
+	tx, err := auth.Signer(types.NewEIP155Signer(lo.chainID), auth.From,
+		types.NewTransaction(0, lo.address, new(big.Int), 60000, new(big.Int), nil))
+	require.NoError(t, err)
+	node := &fakeNode{receipts: map[common.Hash]*types.Receipt{
+		tx.Hash(): testReceipt(tx, types.ReceiptStatusSuccessful, 5)}}
+	ethC := node.dial(t)
+
+	// As if the transaction waited a minute for a poller
+	p := newReceiptPoller(lo, 1)
+	p.pending <- pendingReceipt{tx: tx, ethC: ethC.Client, node: "node-0", sent: time.Now().Add(-time.Minute)}
+	p.Close()
+
+	require.Len(t, p.queued, 1)
+	assert.GreaterOrEqual(t, int64(p.queued[0]), int64(time.Minute))
+	assert.GreaterOrEqual(t, int64(p.latencies[0]), int64(p.queued[0]))
+	// The receipt was found on the first poll
+	assert.Less(t, int64(p.latencies[0]-p.queued[0]), int64(time.Second))
+}
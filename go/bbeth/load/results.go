@@ -0,0 +1,132 @@
+package load
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/robinbryce/benchblock/bbeth/client"
+)
+
+// ResultReport counts the outcome of every transaction issued, for each
+// node. It is safe for concurrent use.
+type ResultReport struct {
+	mu     sync.Mutex
+	counts map[string][]int
+	// The number of reverted transactions for each reason
+	reverts map[string]int
+}
+
+func NewResultReport() *ResultReport {
+	return &ResultReport{counts: map[string][]int{}, reverts: map[string]int{}}
+}
+
+// AddRevert counts the reason for a reverted transaction
+func (rr *ResultReport) AddRevert(reason string) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.reverts[reason]++
+}
+
+// Add counts a result for the node
+func (rr *ResultReport) Add(node string, result client.TxResult) {
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	counts, ok := rr.counts[node]
+	if !ok {
+		counts = make([]int, len(client.TxResults()))
+		rr.counts[node] = counts
+	}
+	counts[result]++
+}
+
+// Count returns the number of transactions with result for the node, or for
+// all nodes if node is empty
+func (rr *ResultReport) Count(node string, result client.TxResult) int {
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	var n int
+	for name, counts := range rr.counts {
+		if node == "" || node == name {
+			n += counts[result]
+		}
+	}
+	return n
+}
+
+// Print writes a table of results by node, with a total row if there is more
+// than one node. Only the results which occurred have a column.
+func (rr *ResultReport) Print() {
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if len(rr.counts) == 0 {
+		return
+	}
+
+	nodes := make([]string, 0, len(rr.counts))
+	total := make([]int, len(client.TxResults()))
+	for node, counts := range rr.counts {
+		nodes = append(nodes, node)
+		for i, n := range counts {
+			total[i] += n
+		}
+	}
+	sort.Strings(nodes)
+
+	var results []client.TxResult
+	for _, result := range client.TxResults() {
+		if total[result] != 0 {
+			results = append(results, result)
+		}
+	}
+
+	fmt.Printf("%-16s", "node")
+	for _, result := range results {
+		fmt.Printf(" %11s", result)
+	}
+	fmt.Printf(" %8s\n", "failed")
+
+	row := func(name string, counts []int) {
+		var all, failed int
+		fmt.Printf("%-16s", name)
+		for _, result := range results {
+			fmt.Printf(" %11d", counts[result])
+			all += counts[result]
+			if result.Failed() {
+				failed += counts[result]
+			}
+		}
+		fmt.Printf(" %7.1f%%\n", 100.0*float64(failed)/float64(all))
+	}
+	for _, node := range nodes {
+		row(node, rr.counts[node])
+	}
+	if len(nodes) > 1 {
+		row("total", total)
+	}
+
+	if len(rr.reverts) == 0 {
+		return
+	}
+	reasons := make([]string, 0, len(rr.reverts))
+	for reason := range rr.reverts {
+		reasons = append(reasons, reason)
+	}
+	// Most frequent first
+	sort.Slice(reasons, func(i, j int) bool {
+		if rr.reverts[reasons[i]] != rr.reverts[reasons[j]] {
+			return rr.reverts[reasons[i]] > rr.reverts[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	fmt.Printf("revert reasons:\n")
+	for _, reason := range reasons {
+		fmt.Printf("  %8d %s\n", rr.reverts[reason], reason)
+	}
+}
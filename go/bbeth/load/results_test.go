@@ -0,0 +1,23 @@
+package load
+
+import (
+	"testing"
+
+	"github.com/robinbryce/benchblock/bbeth/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultReport(t *testing.T) {
+
+	rr := NewResultReport()
+	rr.Add("node-0", client.TxMined)
+	rr.Add("node-0", client.TxMined)
+	rr.Add("node-0", client.TxReverted)
+	rr.Add("node-1", client.TxPoolFull)
+	rr.Add("node-1", client.TxMined)
+
+	assert.Equal(t, 3, rr.Count("", client.TxMined))
+	assert.Equal(t, 2, rr.Count("node-0", client.TxMined))
+	assert.Equal(t, 1, rr.Count("node-1", client.TxPoolFull))
+	assert.Equal(t, 0, rr.Count("node-1", client.TxNonceError))
+}
@@ -2,6 +2,8 @@ package root
 
 import (
 	"time"
+
+	"github.com/robinbryce/benchblock/bbeth/client"
 )
 
 const (
@@ -9,20 +11,62 @@ const (
 )
 
 type Config struct {
-	EthEndpoint   string
+	client.TransportConfig
+
+	EthEndpoint string
+	// Endpoints names a yaml file listing the nodes explicitly. When set it is
+	// used instead of deriving the nodes from EthEndpoint and BasePort.
+	Endpoints     string
 	BasePort      int
 	ClientTimeout time.Duration `mapstructure:"client-timeout"`
 	ResolveHosts  bool
-	Retries       int
-	NoProgress    bool `mapstructure:"no-progress"`
+	// ExpandHosts turns a host name with many addresses into a node for each
+	// address. It implies ResolveHosts.
+	ExpandHosts bool `mapstructure:"expand-hosts"`
+	Retries     int
+	NoProgress  bool `mapstructure:"no-progress"`
+	// LogLevel and LogFormat configure the diagnostic log, which is written
+	// to stderr so it does not mix with progress and report output
+	LogLevel  string `mapstructure:"log-level"`
+	LogFormat string `mapstructure:"log-format"`
+	// MetricsAddr, if set, is the address prometheus metrics are served on
+	MetricsAddr string `mapstructure:"metrics-addr"`
+	// ContractAddress is the hex address of a previously deployed contract,
+	// or the name of the file the loader saved it to. The loader uses it
+	// instead of deploying and the collector only counts transactions to it.
+	ContractAddress string `mapstructure:"contract-address"`
+
+	// AuthToken is sent as a bearer token to every eth endpoint. JWTSecret
+	// names a geth style jwt secret file instead. If neither is set,
+	// credentials in the endpoint url userinfo are sent as basic auth.
+	AuthToken string `mapstructure:"auth-token"`
+	JWTSecret string `mapstructure:"jwt-secret"`
 }
 
 func (cfg *Config) SetDefaults() {
+	cfg.TransportConfig.SetDefaults()
 	cfg.EthEndpoint = ""
+	cfg.Endpoints = ""
 	cfg.BasePort = 8300
 	cfg.ClientTimeout = 60 * time.Second
 	cfg.ResolveHosts = true
+	cfg.ExpandHosts = false
 	cfg.Retries = 50
+	cfg.LogLevel = "info"
+	cfg.LogFormat = client.LogFormatTerminal
+	cfg.ContractAddress = ""
+	cfg.AuthToken = ""
+	cfg.JWTSecret = ""
+}
+
+// EthAuth returns the credentials for the eth endpoints
+func (cfg *Config) EthAuth() client.AuthConfig {
+	return client.AuthConfig{Token: cfg.AuthToken, JWTSecret: cfg.JWTSecret}
+}
+
+// HostResolver returns the resolver for node host names
+func (cfg *Config) HostResolver() client.HostResolver {
+	return client.HostResolver{Resolve: cfg.ResolveHosts || cfg.ExpandHosts, Expand: cfg.ExpandHosts}
 }
 
 func NewConfig() Config {